@@ -26,8 +26,13 @@ type Account struct {
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
 	UserName  string `json:"username"`
-	Created   string `json:"created"`
-	Closed    string `json:"closed"`
+	Created   Time   `json:"created,omitzero"`
+	Closed    Time   `json:"closed,omitzero"`
+}
+
+// IsClosed returns true if the Account has been closed
+func (a *Account) IsClosed() bool {
+	return !a.Closed.IsZero()
 }
 
 // MarshalForUpdate exports only those fields suitable for an update operation
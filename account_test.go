@@ -9,17 +9,13 @@
 //  express or implied. See the License for the specific language
 //  governing permissions and limitations under the License.
 
-package luno
+package luno_test
 
 import "testing"
 
 func TestAccount(t *testing.T) {
 
-	apiKey, secretKey, err := lunoKeysFromEnv()
-	if err != nil {
-		t.Fatal(err)
-	}
-	lunoClient := NewClient(apiKey, secretKey)
+	lunoClient := newTestClient(t)
 
 	// get account info
 	account, err := lunoClient.Account.Get()
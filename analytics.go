@@ -62,7 +62,7 @@ type EventAggregate struct {
 	Entity
 	Name  string `json:"name"`
 	Count int    `json:"count"`
-	Last  string `json:"last"`
+	Last  Time   `json:"last,omitzero"`
 }
 
 // TimelineFilter allows you to filter items returned from an Event Timeline
@@ -109,7 +109,7 @@ type Range struct {
 
 // TimelineEntry represents an point on a Timeline
 type TimelineEntry struct {
-	Timestamp string `json:"timestamp"`
+	Timestamp Time   `json:"timestamp,omitzero"`
 	Range     *Range `json:"range"`
 	Count     int
 }
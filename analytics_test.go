@@ -9,22 +9,20 @@
 //  express or implied. See the License for the specific language
 //  governing permissions and limitations under the License.
 
-package luno
+package luno_test
 
 import (
 	"reflect"
 	"testing"
+
+	"github.com/mschoch/luno-go"
 )
 
 func TestAnalytics(t *testing.T) {
-	apiKey, secretKey, err := lunoKeysFromEnv()
-	if err != nil {
-		t.Fatal(err)
-	}
-	lunoClient := NewClient(apiKey, secretKey)
+	lunoClient := newTestClient(t)
 
 	// create a user and login, so there are some things to look at
-	user := &User{
+	user := &luno.User{
 		Name:     "Ducker Cup",
 		Email:    "d@c.com",
 		Password: "quack",
@@ -44,7 +42,7 @@ func TestAnalytics(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	expected := EntityAggregate{
+	expected := luno.EntityAggregate{
 		"total":   1,
 		"7_days":  1,
 		"28_days": 1,
@@ -58,7 +56,7 @@ func TestAnalytics(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	expected = EntityAggregate{
+	expected = luno.EntityAggregate{
 		"3_days": 1,
 		"9_days": 1,
 	}
@@ -71,7 +69,7 @@ func TestAnalytics(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	expected = EntityAggregate{
+	expected = luno.EntityAggregate{
 		"total":   1,
 		"7_days":  1,
 		"28_days": 1,
@@ -85,7 +83,7 @@ func TestAnalytics(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	expected = EntityAggregate{
+	expected = luno.EntityAggregate{
 		"3_days": 1,
 		"9_days": 1,
 	}
@@ -98,7 +96,7 @@ func TestAnalytics(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	expected = EntityAggregate{
+	expected = luno.EntityAggregate{
 		"total":   4,
 		"7_days":  4,
 		"28_days": 4,
@@ -112,7 +110,7 @@ func TestAnalytics(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	expected = EntityAggregate{
+	expected = luno.EntityAggregate{
 		"3_days": 4,
 		"9_days": 4,
 	}
@@ -50,7 +50,7 @@ type APIAuth struct {
 	UserID  string      `json:"user_id,omitempty"`
 	Key     string      `json:"key,omitempty"`
 	Secret  string      `json:"secret,omitempty"`
-	Created string      `json:"created,omitempty"`
+	Created Time        `json:"created,omitzero"`
 	Details interface{} `json:"details,omitempty"`
 	User    *User       `json:"user,omitempty"`
 }
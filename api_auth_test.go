@@ -9,16 +9,16 @@
 //  express or implied. See the License for the specific language
 //  governing permissions and limitations under the License.
 
-package luno
+package luno_test
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/mschoch/luno-go"
+)
 
 func TestApiAuth(t *testing.T) {
-	apiKey, secretKey, err := lunoKeysFromEnv()
-	if err != nil {
-		t.Fatal(err)
-	}
-	lunoClient := NewClient(apiKey, secretKey)
+	lunoClient := newTestClient(t)
 
 	// get the list of api auths
 	apiAuths, err := lunoClient.APIAuth.Recent(nil, nil, nil)
@@ -30,7 +30,7 @@ func TestApiAuth(t *testing.T) {
 	}
 
 	// create a user
-	newUser := &User{
+	newUser := &luno.User{
 		Name:     "API User",
 		Email:    "api@user.com",
 		Password: "luv2code",
@@ -41,7 +41,7 @@ func TestApiAuth(t *testing.T) {
 	}
 
 	// create an api auth
-	apiAuth := &APIAuth{
+	apiAuth := &luno.APIAuth{
 		UserID: createdUser.ID,
 	}
 	createdAPIAuth, err := lunoClient.APIAuth.Create(apiAuth, nil)
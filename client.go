@@ -13,40 +13,34 @@ package luno
 
 import (
 	"bytes"
-	"crypto/hmac"
-	"crypto/sha512"
-	"encoding/hex"
+	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"time"
 )
 
-// Log allows an application to provie a custom logger for logging
-// care must be taken, as logs may contain sensitive information
-var Log *log.Logger
-
-// LogRequestURL allows you to log the URL of every HTTP request
-var LogRequestURL = false
-
-// LogRequestBody allows you to log the body of every HTTP request
-var LogRequestBody = false
-
-// LogResponseCode allows you to log every HTTP response code
-var LogResponseCode = false
-
-// LogResponseBody allows you to log every HTTP response body
-var LogResponseBody = false
-
 // Client is a Luno Client - https://luno.io/docs/libraries
 type Client struct {
-	host       string
-	version    string
-	apiKey     string
-	secretKey  string
-	httpClient *http.Client
+	scheme       string
+	host         string
+	basePath     string
+	version      string
+	signer       *Signer
+	userAgent    string
+	timeout      time.Duration // negative means not configured
+	transport    http.RoundTripper
+	httpClient   *http.Client
+	retryPolicy  RetryPolicy
+	limiter      *RateLimiter
+	sessionCache *SessionCache
+	middleware   []Middleware
+	doer         Doer
+	logger       *slog.Logger
+	err          error
 
 	Users     *usersClient
 	Events    *eventsClient
@@ -56,17 +50,22 @@ type Client struct {
 	Account   *accountClient
 }
 
-// NewClient builds a new client with the provided API key and secret key
-func NewClient(apiKey, secretKey string) *Client {
+// NewClient builds a new client with the provided API key and secret key,
+// additional options may be provided to customize the client
+func NewClient(apiKey, secretKey string, opts ...Option) *Client {
 	rv := &Client{
-		host:      "api.luno.io",
-		version:   "v1",
-		apiKey:    apiKey,
-		secretKey: secretKey,
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+		scheme:      "https",
+		host:        "api.luno.io",
+		version:     "v1",
+		signer:      NewSigner(apiKey, secretKey),
+		timeout:     -1,
+		retryPolicy: DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(rv)
 	}
+	rv.httpClient = rv.buildHTTPClient()
+	rv.doer = chainMiddleware(rv.httpClient, rv.middleware)
 	rv.Users = &usersClient{rv}
 	rv.Events = &eventsClient{rv}
 	rv.Sessions = &sessionsClient{rv}
@@ -76,67 +75,195 @@ func NewClient(apiKey, secretKey string) *Client {
 	return rv
 }
 
-func (c *Client) request(method, endpoint string, params url.Values, body []byte) (*http.Response, error) {
-	if params == nil {
-		params = make(url.Values)
+// request performs a signed request for the named operation against the
+// Luno API, subject to the configured RateLimiter and retrying according to
+// the RetryPolicy.  Error responses are returned as a *RequestError wrapping
+// the result of ParseError.  If the context is cancelled or its deadline
+// exceeded, the context error is returned.
+func (c *Client) request(ctx context.Context, op, method, endpoint string, params url.Values, body []byte) (*http.Response, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	for attempt := 1; ; attempt++ {
+		err := c.limiter.Wait(ctx)
+		if err != nil {
+			return nil, err
+		}
+		attemptCtx := withRequestInfo(ctx, requestInfo{operation: op, attempt: attempt, start: time.Now()})
+		resp, err := c.attempt(attemptCtx, method, endpoint, params, body)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+		}
+		var delay time.Duration
+		if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+			retryAfter, hasRetryAfter := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+			var ok bool
+			delay, ok = c.retryPolicy.rateLimitDelay(attempt, retryAfter, hasRetryAfter)
+			if !ok {
+				err = responseError(resp)
+				_ = resp.Body.Close()
+				return nil, &RateLimitError{
+					RetryAfter: retryAfter,
+					Attempts:   attempt,
+					Err:        err,
+				}
+			}
+		} else if c.retryPolicy.shouldRetry(method, attempt, resp, err) {
+			delay = c.retryPolicy.backoff(attempt)
+		} else if resp != nil && resp.StatusCode >= http.StatusMultipleChoices {
+			rerr := responseError(resp)
+			_ = resp.Body.Close()
+			return nil, rerr
+		} else {
+			return resp, err
+		}
+		c.logRetry(attemptCtx, method, endpoint, resp, err, delay)
+		if resp != nil {
+			// discard this response so the connection can be reused
+			_, _ = io.Copy(ioutil.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+		err = sleepContext(ctx, delay)
+		if err != nil {
+			return nil, err
+		}
 	}
-	params.Add("key", c.apiKey)
-	params.Add("timestamp", c.timestamp())
+}
+
+// attempt makes a single signed request, each attempt gets a fresh
+// timestamp and signature, and a fresh reader over the body
+func (c *Client) attempt(ctx context.Context, method, endpoint string, params url.Values, body []byte) (*http.Response, error) {
 	req := &http.Request{
 		Method: method,
 		URL: &url.URL{
 			Host:   c.host,
-			Scheme: "https",
-			Opaque: "/" + c.version + endpoint + "?" + params.Encode(),
+			Scheme: c.scheme,
+			Opaque: c.basePath + "/" + c.version + endpoint + "?" + params.Encode(),
 		},
 		Body:          ioutil.NopCloser(bytes.NewReader(body)),
 		ContentLength: int64(len(body)),
 		Header:        make(http.Header),
 	}
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
 	req.Header.Set("Content-Type", "application/json")
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	req = req.WithContext(ctx)
 
-	// sign and add signature to request
-	sign, err := c.signRequest(req, body)
+	// add key, timestamp and signature to request
+	err := c.signer.SignRequest(req)
 	if err != nil {
 		return nil, fmt.Errorf("error signing request: %v", err)
 	}
-	req.URL.Opaque += "&sign=" + sign
-	if Log != nil && LogRequestURL {
-		Log.Printf("%s request: %s", req.Method, req.URL.Opaque)
+	c.logRequest(ctx, req, endpoint, body)
+	start := time.Now()
+	resp, err := c.doer.Do(req)
+	if err != nil {
+		c.logFailure(ctx, req, endpoint, time.Since(start), err)
+		return nil, newRequestError(req, err)
+	}
+	c.logResponse(ctx, req, endpoint, resp, time.Since(start))
+
+	return resp, nil
+}
+
+func (c *Client) logRequest(ctx context.Context, req *http.Request, endpoint string, body []byte) {
+	if c.logger == nil || !c.logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("op", OperationFromContext(ctx)),
+		slog.String("method", req.Method),
+		slog.String("endpoint", endpoint),
+		slog.Int("attempt", AttemptFromContext(ctx)),
+		slog.String("url", redactURL(req.URL.Opaque)),
+	}
+	if len(body) > 0 && c.logger.Enabled(ctx, LevelTrace) {
+		c.logger.LogAttrs(ctx, LevelTrace, "luno request", append(attrs, slog.String("body", redactJSON(body)))...)
+		return
+	}
+	c.logger.LogAttrs(ctx, slog.LevelDebug, "luno request", attrs...)
+}
+
+func (c *Client) logResponse(ctx context.Context, req *http.Request, endpoint string, resp *http.Response, latency time.Duration) {
+	if c.logger == nil || !c.logger.Enabled(ctx, slog.LevelDebug) {
+		return
 	}
-	if len(body) > 0 && Log != nil && LogRequestBody {
-		Log.Print(string(body))
+	attrs := []slog.Attr{
+		slog.String("op", OperationFromContext(ctx)),
+		slog.String("method", req.Method),
+		slog.String("endpoint", endpoint),
+		slog.Int("attempt", AttemptFromContext(ctx)),
+		slog.Int("status", resp.StatusCode),
+		slog.Duration("latency", latency),
 	}
-	resp, err := c.httpClient.Do(req)
-	if Log != nil && LogResponseCode {
-		Log.Printf("response %d", resp.StatusCode)
+	if requestID := resp.Header.Get("X-Request-Id"); requestID != "" {
+		attrs = append(attrs, slog.String("request_id", requestID))
 	}
-	if resp.Body != nil && Log != nil && LogResponseBody {
+	if resp.Body != nil && c.logger.Enabled(ctx, LevelTrace) {
 		respBody, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return nil, fmt.Errorf("error reading response body for logging")
+		_ = resp.Body.Close()
+		resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+		if err == nil {
+			c.logger.LogAttrs(ctx, LevelTrace, "luno response", append(attrs, slog.String("body", redactJSON(respBody)))...)
+			return
 		}
-		Log.Print(string(respBody))
-		resp.Body = ioutil.NopCloser(bytes.NewBuffer(respBody))
 	}
-
-	return resp, err
+	c.logger.LogAttrs(ctx, slog.LevelDebug, "luno response", attrs...)
 }
 
-func (c *Client) timestamp() string {
-	now := time.Now()
-	return now.Format(time.RFC3339)
+func (c *Client) logFailure(ctx context.Context, req *http.Request, endpoint string, latency time.Duration, err error) {
+	if c.logger == nil {
+		return
+	}
+	c.logger.LogAttrs(ctx, slog.LevelWarn, "luno request failed",
+		slog.String("op", OperationFromContext(ctx)),
+		slog.String("method", req.Method),
+		slog.String("endpoint", endpoint),
+		slog.Int("attempt", AttemptFromContext(ctx)),
+		slog.Duration("latency", latency),
+		slog.Any("error", redactError(err)))
 }
 
-func (c *Client) signRequest(req *http.Request, body []byte) (string, error) {
-	msg := req.Method + ":" + req.URL.Opaque
-	if len(body) > 0 {
-		msg += ":" + string(body)
+func (c *Client) logRetry(ctx context.Context, method, endpoint string, resp *http.Response, err error, delay time.Duration) {
+	if c.logger == nil {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("op", OperationFromContext(ctx)),
+		slog.String("method", method),
+		slog.String("endpoint", endpoint),
+		slog.Int("attempt", AttemptFromContext(ctx)),
+		slog.Duration("delay", delay),
+	}
+	if resp != nil {
+		attrs = append(attrs, slog.Int("status", resp.StatusCode))
 	}
-	mac := hmac.New(sha512.New, []byte(c.secretKey))
-	_, err := mac.Write([]byte(msg))
 	if err != nil {
-		return "", err
+		attrs = append(attrs, slog.Any("error", redactError(err)))
+	}
+	c.logger.LogAttrs(ctx, slog.LevelInfo, "luno retrying request", attrs...)
+}
+
+// buildHTTPClient combines the configured http.Client, timeout and transport,
+// a caller provided http.Client is copied rather than modified
+func (c *Client) buildHTTPClient() *http.Client {
+	var rv http.Client
+	if c.httpClient != nil {
+		rv = *c.httpClient
+	} else {
+		rv.Timeout = 10 * time.Second
+	}
+	if c.timeout >= 0 {
+		rv.Timeout = c.timeout
+	}
+	if c.transport != nil {
+		rv.Transport = c.transport
 	}
-	return hex.EncodeToString(mac.Sum(nil)), nil
+	return &rv
 }
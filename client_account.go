@@ -12,6 +12,7 @@
 package luno
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -22,7 +23,11 @@ type accountClient struct {
 }
 
 func (c *accountClient) Get() (*Account, error) {
-	resp, err := c.request(http.MethodGet, "/account", nil, nil)
+	return c.GetContext(context.Background())
+}
+
+func (c *accountClient) GetContext(ctx context.Context) (*Account, error) {
+	resp, err := c.request(ctx, "account.get", http.MethodGet, "/account", nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -33,6 +38,10 @@ func (c *accountClient) Get() (*Account, error) {
 }
 
 func (c *accountClient) Update(account *Account, autoName bool) error {
+	return c.UpdateContext(context.Background(), account, autoName)
+}
+
+func (c *accountClient) UpdateContext(ctx context.Context, account *Account, autoName bool) error {
 	params := make(url.Values)
 	params.Add("auto_name", fmt.Sprintf("%t", autoName))
 	if autoName {
@@ -43,7 +52,7 @@ func (c *accountClient) Update(account *Account, autoName bool) error {
 	if err != nil {
 		return fmt.Errorf("error marshaling user json: %v", err)
 	}
-	resp, err := c.request(http.MethodPut, "/account", params, accountJSON)
+	resp, err := c.request(ctx, "account.update", http.MethodPut, "/account", params, accountJSON)
 	if err != nil {
 		return err
 	}
@@ -54,5 +63,9 @@ func (c *accountClient) Update(account *Account, autoName bool) error {
 }
 
 func (c *accountClient) Delete(token string) error {
+	return c.DeleteContext(context.Background(), token)
+}
+
+func (c *accountClient) DeleteContext(ctx context.Context, token string) error {
 	return ErrNotImplemented
 }
@@ -12,6 +12,7 @@
 package luno
 
 import (
+	"context"
 	"net/http"
 	"net/url"
 )
@@ -21,11 +22,15 @@ type analyticsClient struct {
 }
 
 func (c *analyticsClient) Users(days []string) (EntityAggregate, error) {
+	return c.UsersContext(context.Background(), days)
+}
+
+func (c *analyticsClient) UsersContext(ctx context.Context, days []string) (EntityAggregate, error) {
 	params := make(url.Values)
 	for _, day := range days {
 		params.Add("days", day)
 	}
-	resp, err := c.request(http.MethodGet, "/analytics/users", params, nil)
+	resp, err := c.request(ctx, "analytics.users", http.MethodGet, "/analytics/users", params, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -36,11 +41,15 @@ func (c *analyticsClient) Users(days []string) (EntityAggregate, error) {
 }
 
 func (c *analyticsClient) Sessions(days []string) (EntityAggregate, error) {
+	return c.SessionsContext(context.Background(), days)
+}
+
+func (c *analyticsClient) SessionsContext(ctx context.Context, days []string) (EntityAggregate, error) {
 	params := make(url.Values)
 	for _, day := range days {
 		params.Add("days", day)
 	}
-	resp, err := c.request(http.MethodGet, "/analytics/sessions", params, nil)
+	resp, err := c.request(ctx, "analytics.sessions", http.MethodGet, "/analytics/sessions", params, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -51,11 +60,15 @@ func (c *analyticsClient) Sessions(days []string) (EntityAggregate, error) {
 }
 
 func (c *analyticsClient) Events(days []string) (EntityAggregate, error) {
+	return c.EventsContext(context.Background(), days)
+}
+
+func (c *analyticsClient) EventsContext(ctx context.Context, days []string) (EntityAggregate, error) {
 	params := make(url.Values)
 	for _, day := range days {
 		params.Add("days", day)
 	}
-	resp, err := c.request(http.MethodGet, "/analytics/events", params, nil)
+	resp, err := c.request(ctx, "analytics.events", http.MethodGet, "/analytics/events", params, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -66,7 +79,11 @@ func (c *analyticsClient) Events(days []string) (EntityAggregate, error) {
 }
 
 func (c *analyticsClient) EventsList() (*EventAggregates, error) {
-	resp, err := c.request(http.MethodGet, "/analytics/events/list", nil, nil)
+	return c.EventsListContext(context.Background())
+}
+
+func (c *analyticsClient) EventsListContext(ctx context.Context) (*EventAggregates, error) {
+	resp, err := c.request(ctx, "analytics.events_list", http.MethodGet, "/analytics/events/list", nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -77,8 +94,12 @@ func (c *analyticsClient) EventsList() (*EventAggregates, error) {
 }
 
 func (c *analyticsClient) EventsTimeline(filter *TimelineFilter) (*EventsTimeline, error) {
+	return c.EventsTimelineContext(context.Background(), filter)
+}
+
+func (c *analyticsClient) EventsTimelineContext(ctx context.Context, filter *TimelineFilter) (*EventsTimeline, error) {
 	params := filter.Params()
-	resp, err := c.request(http.MethodGet, "/analytics/events/timeline", params, nil)
+	resp, err := c.request(ctx, "analytics.events_timeline", http.MethodGet, "/analytics/events/timeline", params, nil)
 	if err != nil {
 		return nil, err
 	}
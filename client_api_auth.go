@@ -12,8 +12,10 @@
 package luno
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"iter"
 	"net/http"
 	"net/url"
 )
@@ -23,6 +25,10 @@ type apiAuthClient struct {
 }
 
 func (c *apiAuthClient) Recent(expand []string, filter *APIAuthFilter, paging *Paging) (*APIAuths, error) {
+	return c.RecentContext(context.Background(), expand, filter, paging)
+}
+
+func (c *apiAuthClient) RecentContext(ctx context.Context, expand []string, filter *APIAuthFilter, paging *Paging) (*APIAuths, error) {
 	params := paging.Params()
 	for _, item := range expand {
 		params.Add("expand", item)
@@ -30,7 +36,7 @@ func (c *apiAuthClient) Recent(expand []string, filter *APIAuthFilter, paging *P
 	if filter != nil && filter.UserID != "" {
 		params.Add("user_id", filter.UserID)
 	}
-	resp, err := c.request(http.MethodGet, "/api_authentication", params, nil)
+	resp, err := c.request(ctx, "api_auth.recent", http.MethodGet, "/api_authentication", params, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -40,7 +46,25 @@ func (c *apiAuthClient) Recent(expand []string, filter *APIAuthFilter, paging *P
 	return nil, ParseError(resp)
 }
 
+func (c *apiAuthClient) Iter(ctx context.Context, expand []string, filter *APIAuthFilter, paging *Paging) *Iterator[*APIAuth] {
+	return newIterator(ctx, paging, func(ctx context.Context, paging *Paging) ([]*APIAuth, Page, error) {
+		list, err := c.RecentContext(ctx, expand, filter, paging)
+		if err != nil {
+			return nil, Page{}, err
+		}
+		return list.List, list.Page, nil
+	})
+}
+
+func (c *apiAuthClient) All(ctx context.Context, expand []string, filter *APIAuthFilter, paging *Paging) iter.Seq2[*APIAuth, error] {
+	return c.Iter(ctx, expand, filter, paging).All()
+}
+
 func (c *apiAuthClient) Create(apiAuth *APIAuth, expand []string) (*APIAuth, error) {
+	return c.CreateContext(context.Background(), apiAuth, expand)
+}
+
+func (c *apiAuthClient) CreateContext(ctx context.Context, apiAuth *APIAuth, expand []string) (*APIAuth, error) {
 	params := make(url.Values)
 	for _, item := range expand {
 		params.Add("expand", item)
@@ -49,7 +73,7 @@ func (c *apiAuthClient) Create(apiAuth *APIAuth, expand []string) (*APIAuth, err
 	if err != nil {
 		return nil, fmt.Errorf("error marshaling session json: %v", err)
 	}
-	resp, err := c.request(http.MethodPost, "/api_authentication", params, apiAuthJSON)
+	resp, err := c.request(ctx, "api_auth.create", http.MethodPost, "/api_authentication", params, apiAuthJSON)
 	if err != nil {
 		return nil, err
 	}
@@ -60,11 +84,15 @@ func (c *apiAuthClient) Create(apiAuth *APIAuth, expand []string) (*APIAuth, err
 }
 
 func (c *apiAuthClient) Get(id string, expand []string) (*APIAuth, error) {
+	return c.GetContext(context.Background(), id, expand)
+}
+
+func (c *apiAuthClient) GetContext(ctx context.Context, id string, expand []string) (*APIAuth, error) {
 	params := make(url.Values)
 	for _, item := range expand {
 		params.Add("expand", item)
 	}
-	resp, err := c.request(http.MethodGet, "/api_authentication/"+id, params, nil)
+	resp, err := c.request(ctx, "api_auth.get", http.MethodGet, "/api_authentication/"+id, params, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -75,6 +103,10 @@ func (c *apiAuthClient) Get(id string, expand []string) (*APIAuth, error) {
 }
 
 func (c *apiAuthClient) Update(apiAuth *APIAuth, overwriteProfile bool) error {
+	return c.UpdateContext(context.Background(), apiAuth, overwriteProfile)
+}
+
+func (c *apiAuthClient) UpdateContext(ctx context.Context, apiAuth *APIAuth, overwriteProfile bool) error {
 	method := http.MethodPatch
 	if overwriteProfile {
 		method = http.MethodPut
@@ -83,7 +115,7 @@ func (c *apiAuthClient) Update(apiAuth *APIAuth, overwriteProfile bool) error {
 	if err != nil {
 		return fmt.Errorf("error marshaling api auth json: %v", err)
 	}
-	resp, err := c.request(method, "/api_authentication/"+apiAuth.Key, nil, apiAuthJSON)
+	resp, err := c.request(ctx, "api_auth.update", method, "/api_authentication/"+apiAuth.Key, nil, apiAuthJSON)
 	if err != nil {
 		return err
 	}
@@ -94,7 +126,11 @@ func (c *apiAuthClient) Update(apiAuth *APIAuth, overwriteProfile bool) error {
 }
 
 func (c *apiAuthClient) Delete(id string) error {
-	resp, err := c.request(http.MethodDelete, "/api_authentication/"+id, nil, nil)
+	return c.DeleteContext(context.Background(), id)
+}
+
+func (c *apiAuthClient) DeleteContext(ctx context.Context, id string) error {
+	resp, err := c.request(ctx, "api_auth.delete", http.MethodDelete, "/api_authentication/"+id, nil, nil)
 	if err != nil {
 		return err
 	}
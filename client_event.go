@@ -12,8 +12,10 @@
 package luno
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"iter"
 	"net/http"
 	"net/url"
 )
@@ -23,6 +25,10 @@ type eventsClient struct {
 }
 
 func (c *eventsClient) Recent(expand []string, filter *EventFilter, paging *Paging) (*Events, error) {
+	return c.RecentContext(context.Background(), expand, filter, paging)
+}
+
+func (c *eventsClient) RecentContext(ctx context.Context, expand []string, filter *EventFilter, paging *Paging) (*Events, error) {
 	params := paging.Params()
 	for _, item := range expand {
 		params.Add("expand", item)
@@ -35,7 +41,7 @@ func (c *eventsClient) Recent(expand []string, filter *EventFilter, paging *Pagi
 			params.Add("name", filter.Name)
 		}
 	}
-	resp, err := c.request(http.MethodGet, "/events", params, nil)
+	resp, err := c.request(ctx, "events.recent", http.MethodGet, "/events", params, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -45,7 +51,25 @@ func (c *eventsClient) Recent(expand []string, filter *EventFilter, paging *Pagi
 	return nil, ParseError(resp)
 }
 
+func (c *eventsClient) Iter(ctx context.Context, expand []string, filter *EventFilter, paging *Paging) *Iterator[*Event] {
+	return newIterator(ctx, paging, func(ctx context.Context, paging *Paging) ([]*Event, Page, error) {
+		list, err := c.RecentContext(ctx, expand, filter, paging)
+		if err != nil {
+			return nil, Page{}, err
+		}
+		return list.List, list.Page, nil
+	})
+}
+
+func (c *eventsClient) All(ctx context.Context, expand []string, filter *EventFilter, paging *Paging) iter.Seq2[*Event, error] {
+	return c.Iter(ctx, expand, filter, paging).All()
+}
+
 func (c *eventsClient) Create(event *Event, expand []string) (*Event, error) {
+	return c.CreateContext(context.Background(), event, expand)
+}
+
+func (c *eventsClient) CreateContext(ctx context.Context, event *Event, expand []string) (*Event, error) {
 	params := make(url.Values)
 	for _, item := range expand {
 		params.Add("expand", item)
@@ -54,7 +78,7 @@ func (c *eventsClient) Create(event *Event, expand []string) (*Event, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error marshaling event json: %v", err)
 	}
-	resp, err := c.request(http.MethodPost, "/events", params, eventJSON)
+	resp, err := c.request(ctx, "events.create", http.MethodPost, "/events", params, eventJSON)
 	if err != nil {
 		return nil, err
 	}
@@ -65,7 +89,11 @@ func (c *eventsClient) Create(event *Event, expand []string) (*Event, error) {
 }
 
 func (c *eventsClient) Get(id string) (*Event, error) {
-	resp, err := c.request(http.MethodGet, "/events/"+id, nil, nil)
+	return c.GetContext(context.Background(), id)
+}
+
+func (c *eventsClient) GetContext(ctx context.Context, id string) (*Event, error) {
+	resp, err := c.request(ctx, "events.get", http.MethodGet, "/events/"+id, nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -76,6 +104,10 @@ func (c *eventsClient) Get(id string) (*Event, error) {
 }
 
 func (c *eventsClient) Update(event *Event, overwriteDetails bool) error {
+	return c.UpdateContext(context.Background(), event, overwriteDetails)
+}
+
+func (c *eventsClient) UpdateContext(ctx context.Context, event *Event, overwriteDetails bool) error {
 	method := http.MethodPatch
 	if overwriteDetails {
 		method = http.MethodPut
@@ -84,7 +116,7 @@ func (c *eventsClient) Update(event *Event, overwriteDetails bool) error {
 	if err != nil {
 		return fmt.Errorf("error marshaling event json: %v", err)
 	}
-	resp, err := c.request(method, "/events/"+event.ID, nil, eventJSON)
+	resp, err := c.request(ctx, "events.update", method, "/events/"+event.ID, nil, eventJSON)
 	if err != nil {
 		return err
 	}
@@ -95,7 +127,11 @@ func (c *eventsClient) Update(event *Event, overwriteDetails bool) error {
 }
 
 func (c *eventsClient) Delete(id string) error {
-	resp, err := c.request(http.MethodDelete, "/events/"+id, nil, nil)
+	return c.DeleteContext(context.Background(), id)
+}
+
+func (c *eventsClient) DeleteContext(ctx context.Context, id string) error {
+	resp, err := c.request(ctx, "events.delete", http.MethodDelete, "/events/"+id, nil, nil)
 	if err != nil {
 		return err
 	}
@@ -12,8 +12,10 @@
 package luno
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"iter"
 	"net/http"
 	"net/url"
 )
@@ -23,6 +25,10 @@ type sessionsClient struct {
 }
 
 func (c *sessionsClient) Recent(expand []string, filter *SessionFilter, paging *Paging) (*Sessions, error) {
+	return c.RecentContext(context.Background(), expand, filter, paging)
+}
+
+func (c *sessionsClient) RecentContext(ctx context.Context, expand []string, filter *SessionFilter, paging *Paging) (*Sessions, error) {
 	params := paging.Params()
 	for _, item := range expand {
 		params.Add("expand", item)
@@ -30,7 +36,7 @@ func (c *sessionsClient) Recent(expand []string, filter *SessionFilter, paging *
 	if filter != nil && filter.UserID != "" {
 		params.Add("user_id", filter.UserID)
 	}
-	resp, err := c.request(http.MethodGet, "/sessions", params, nil)
+	resp, err := c.request(ctx, "sessions.recent", http.MethodGet, "/sessions", params, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -40,7 +46,25 @@ func (c *sessionsClient) Recent(expand []string, filter *SessionFilter, paging *
 	return nil, ParseError(resp)
 }
 
+func (c *sessionsClient) Iter(ctx context.Context, expand []string, filter *SessionFilter, paging *Paging) *Iterator[*Session] {
+	return newIterator(ctx, paging, func(ctx context.Context, paging *Paging) ([]*Session, Page, error) {
+		list, err := c.RecentContext(ctx, expand, filter, paging)
+		if err != nil {
+			return nil, Page{}, err
+		}
+		return list.List, list.Page, nil
+	})
+}
+
+func (c *sessionsClient) All(ctx context.Context, expand []string, filter *SessionFilter, paging *Paging) iter.Seq2[*Session, error] {
+	return c.Iter(ctx, expand, filter, paging).All()
+}
+
 func (c *sessionsClient) Create(session *Session, expand []string) (*Session, error) {
+	return c.CreateContext(context.Background(), session, expand)
+}
+
+func (c *sessionsClient) CreateContext(ctx context.Context, session *Session, expand []string) (*Session, error) {
 	params := make(url.Values)
 	for _, item := range expand {
 		params.Add("expand", item)
@@ -49,7 +73,7 @@ func (c *sessionsClient) Create(session *Session, expand []string) (*Session, er
 	if err != nil {
 		return nil, fmt.Errorf("error marshaling session json: %v", err)
 	}
-	resp, err := c.request(http.MethodPost, "/sessions", params, sessionJSON)
+	resp, err := c.request(ctx, "sessions.create", http.MethodPost, "/sessions", params, sessionJSON)
 	if err != nil {
 		return nil, err
 	}
@@ -60,7 +84,12 @@ func (c *sessionsClient) Create(session *Session, expand []string) (*Session, er
 }
 
 func (c *sessionsClient) Delete(id string) error {
-	resp, err := c.request(http.MethodDelete, "/sessions/"+id, nil, nil)
+	return c.DeleteContext(context.Background(), id)
+}
+
+func (c *sessionsClient) DeleteContext(ctx context.Context, id string) error {
+	defer c.sessionCache.InvalidateSession(id)
+	resp, err := c.request(ctx, "sessions.delete", http.MethodDelete, "/sessions/"+id, nil, nil)
 	if err != nil {
 		return err
 	}
@@ -71,7 +100,20 @@ func (c *sessionsClient) Delete(id string) error {
 }
 
 func (c *sessionsClient) Get(id string) (*Session, error) {
-	resp, err := c.request(http.MethodGet, "/sessions/"+id, nil, nil)
+	return c.GetContext(context.Background(), id)
+}
+
+func (c *sessionsClient) GetContext(ctx context.Context, id string) (*Session, error) {
+	if c.sessionCache != nil {
+		return c.sessionCache.load(ctx, sessionCacheIDKey(id), false, func(ctx context.Context) (*Session, error) {
+			return c.get(ctx, id)
+		})
+	}
+	return c.get(ctx, id)
+}
+
+func (c *sessionsClient) get(ctx context.Context, id string) (*Session, error) {
+	resp, err := c.request(ctx, "sessions.get", http.MethodGet, "/sessions/"+id, nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -82,6 +124,10 @@ func (c *sessionsClient) Get(id string) (*Session, error) {
 }
 
 func (c *sessionsClient) Update(session *Session, overwriteDetails bool) error {
+	return c.UpdateContext(context.Background(), session, overwriteDetails)
+}
+
+func (c *sessionsClient) UpdateContext(ctx context.Context, session *Session, overwriteDetails bool) error {
 	method := http.MethodPatch
 	if overwriteDetails {
 		method = http.MethodPut
@@ -90,7 +136,8 @@ func (c *sessionsClient) Update(session *Session, overwriteDetails bool) error {
 	if err != nil {
 		return fmt.Errorf("error marshaling session json: %v", err)
 	}
-	resp, err := c.request(method, "/sessions/"+session.ID, nil, sessionJSON)
+	defer c.sessionCache.InvalidateSession(session.ID)
+	resp, err := c.request(ctx, "sessions.update", method, "/sessions/"+session.ID, nil, sessionJSON)
 	if err != nil {
 		return err
 	}
@@ -101,6 +148,19 @@ func (c *sessionsClient) Update(session *Session, overwriteDetails bool) error {
 }
 
 func (c *sessionsClient) Access(session *Session, expand []string) (*Session, error) {
+	return c.AccessContext(context.Background(), session, expand)
+}
+
+func (c *sessionsClient) AccessContext(ctx context.Context, session *Session, expand []string) (*Session, error) {
+	if c.sessionCache != nil && session.Key != "" {
+		return c.sessionCache.load(ctx, sessionCacheAccessKey(session.Key, expand), true, func(ctx context.Context) (*Session, error) {
+			return c.access(ctx, session, expand)
+		})
+	}
+	return c.access(ctx, session, expand)
+}
+
+func (c *sessionsClient) access(ctx context.Context, session *Session, expand []string) (*Session, error) {
 	params := make(url.Values)
 	for _, item := range expand {
 		params.Add("expand", item)
@@ -109,7 +169,7 @@ func (c *sessionsClient) Access(session *Session, expand []string) (*Session, er
 	if err != nil {
 		return nil, fmt.Errorf("error marshaling session json: %v", err)
 	}
-	resp, err := c.request(http.MethodPost, "/sessions/access", nil, sessionJSON)
+	resp, err := c.request(ctx, "sessions.access", http.MethodPost, "/sessions/access", params, sessionJSON)
 	if err != nil {
 		return nil, err
 	}
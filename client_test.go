@@ -12,24 +12,24 @@
 package luno
 
 import (
-	"fmt"
-	"log"
+	"context"
+	"log/slog"
 	"os"
+	"testing"
 )
 
-func turnOnLogging() {
-	Log = log.New(os.Stderr, "luno: ", log.LstdFlags)
-	LogRequestURL = true
-	LogRequestBody = true
-	LogResponseCode = true
-	LogResponseBody = true
+func turnOnLogging() Option {
+	return WithLogger(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: LevelTrace})))
 }
 
-func lunoKeysFromEnv() (string, string, error) {
-	apiKey := os.Getenv("LUNO_API_KEY")
-	secretKey := os.Getenv("LUNO_SECRET_KEY")
-	if apiKey == "" || secretKey == "" {
-		return "", "", fmt.Errorf("please set LUNO_API_KEY and LUNO_SECRET_KEY")
+func TestRequestContextCanceled(t *testing.T) {
+	lunoClient := NewClient("key", "secret")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := lunoClient.Users.GetContext(ctx, "usr_xxxxxxxxxxxxxxxxxxxxxxxx")
+	if err != context.Canceled {
+		t.Errorf("expected context canceled, got %v", err)
 	}
-	return apiKey, secretKey, nil
 }
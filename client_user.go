@@ -12,10 +12,13 @@
 package luno
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"iter"
 	"net/http"
 	"net/url"
+	"sync"
 )
 
 type usersClient struct {
@@ -23,11 +26,15 @@ type usersClient struct {
 }
 
 func (c *usersClient) Recent(expand []string, paging *Paging) (*Users, error) {
+	return c.RecentContext(context.Background(), expand, paging)
+}
+
+func (c *usersClient) RecentContext(ctx context.Context, expand []string, paging *Paging) (*Users, error) {
 	params := paging.Params()
 	for _, item := range expand {
 		params.Add("expand", item)
 	}
-	resp, err := c.request(http.MethodGet, "/users", params, nil)
+	resp, err := c.request(ctx, "users.recent", http.MethodGet, "/users", params, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -37,7 +44,25 @@ func (c *usersClient) Recent(expand []string, paging *Paging) (*Users, error) {
 	return nil, ParseError(resp)
 }
 
+func (c *usersClient) Iter(ctx context.Context, expand []string, paging *Paging) *Iterator[*User] {
+	return newIterator(ctx, paging, func(ctx context.Context, paging *Paging) ([]*User, Page, error) {
+		list, err := c.RecentContext(ctx, expand, paging)
+		if err != nil {
+			return nil, Page{}, err
+		}
+		return list.List, list.Page, nil
+	})
+}
+
+func (c *usersClient) All(ctx context.Context, expand []string, paging *Paging) iter.Seq2[*User, error] {
+	return c.Iter(ctx, expand, paging).All()
+}
+
 func (c *usersClient) Create(user *User, autoName bool, expand []string) (*User, error) {
+	return c.CreateContext(context.Background(), user, autoName, expand)
+}
+
+func (c *usersClient) CreateContext(ctx context.Context, user *User, autoName bool, expand []string) (*User, error) {
 	params := make(url.Values)
 	for _, item := range expand {
 		params.Add("expand", item)
@@ -47,7 +72,7 @@ func (c *usersClient) Create(user *User, autoName bool, expand []string) (*User,
 	if err != nil {
 		return nil, fmt.Errorf("error marshaling user json: %v", err)
 	}
-	resp, err := c.request(http.MethodPost, "/users", params, userJSON)
+	resp, err := c.request(ctx, "users.create", http.MethodPost, "/users", params, userJSON)
 	if err != nil {
 		return nil, err
 	}
@@ -58,6 +83,10 @@ func (c *usersClient) Create(user *User, autoName bool, expand []string) (*User,
 }
 
 func (c *usersClient) Update(user *User, autoName bool, overwriteProfile bool) error {
+	return c.UpdateContext(context.Background(), user, autoName, overwriteProfile)
+}
+
+func (c *usersClient) UpdateContext(ctx context.Context, user *User, autoName bool, overwriteProfile bool) error {
 	params := make(url.Values)
 	params.Add("auto_name", fmt.Sprintf("%t", autoName))
 	method := http.MethodPatch
@@ -72,7 +101,7 @@ func (c *usersClient) Update(user *User, autoName bool, overwriteProfile bool) e
 	if err != nil {
 		return fmt.Errorf("error marshaling user json: %v", err)
 	}
-	resp, err := c.request(method, "/users/"+user.ID, params, userJSON)
+	resp, err := c.request(ctx, "users.update", method, "/users/"+user.ID, params, userJSON)
 	if err != nil {
 		return err
 	}
@@ -82,10 +111,15 @@ func (c *usersClient) Update(user *User, autoName bool, overwriteProfile bool) e
 	return ParseError(resp)
 }
 
-func (c *usersClient) delete(id string, permanent bool) error {
+func (c *usersClient) delete(ctx context.Context, id string, permanent bool) error {
 	params := make(url.Values)
 	params.Add("permanent", fmt.Sprintf("%t", permanent))
-	resp, err := c.request(http.MethodDelete, "/users/"+id, params, nil)
+	op := "users.deactivate"
+	if permanent {
+		op = "users.delete"
+	}
+	defer c.sessionCache.InvalidateUser(id)
+	resp, err := c.request(ctx, op, http.MethodDelete, "/users/"+id, params, nil)
 	if err != nil {
 		return err
 	}
@@ -96,15 +130,27 @@ func (c *usersClient) delete(id string, permanent bool) error {
 }
 
 func (c *usersClient) Delete(id string) error {
-	return c.delete(id, true)
+	return c.DeleteContext(context.Background(), id)
+}
+
+func (c *usersClient) DeleteContext(ctx context.Context, id string) error {
+	return c.delete(ctx, id, true)
 }
 
 func (c *usersClient) Deactivate(id string) error {
-	return c.delete(id, false)
+	return c.DeactivateContext(context.Background(), id)
+}
+
+func (c *usersClient) DeactivateContext(ctx context.Context, id string) error {
+	return c.delete(ctx, id, false)
 }
 
 func (c *usersClient) Reactivate(id string) error {
-	resp, err := c.request(http.MethodPost, "/users/"+id+"/reactivate", nil, nil)
+	return c.ReactivateContext(context.Background(), id)
+}
+
+func (c *usersClient) ReactivateContext(ctx context.Context, id string) error {
+	resp, err := c.request(ctx, "users.reactivate", http.MethodPost, "/users/"+id+"/reactivate", nil, nil)
 	if err != nil {
 		return err
 	}
@@ -115,7 +161,15 @@ func (c *usersClient) Reactivate(id string) error {
 }
 
 func (c *usersClient) Get(id string) (*User, error) {
-	resp, err := c.request(http.MethodGet, "/users/"+id, nil, nil)
+	return c.GetContext(context.Background(), id)
+}
+
+func (c *usersClient) GetContext(ctx context.Context, id string) (*User, error) {
+	return c.get(ctx, "users.get", id)
+}
+
+func (c *usersClient) get(ctx context.Context, op, ident string) (*User, error) {
+	resp, err := c.request(ctx, op, http.MethodGet, "/users/"+ident, nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -125,7 +179,102 @@ func (c *usersClient) Get(id string) (*User, error) {
 	return nil, ParseError(resp)
 }
 
-func (c *usersClient) login(expand []string, login *Login) (*User, *Session, error) {
+// getBy looks up a user by a field Luno accepts as a prefixed identifier,
+// such as "email:marty@example.com"
+func (c *usersClient) getBy(ctx context.Context, field, value string) (*User, error) {
+	if value == "" {
+		return nil, fmt.Errorf("luno user %s required", field)
+	}
+	user, err := c.get(ctx, "users.get_by_"+field, url.PathEscape(field+":"+value))
+	if IsNotFound(err) {
+		return nil, &UserNotFoundError{Field: field, Value: value, Err: err}
+	}
+	return user, err
+}
+
+func (c *usersClient) GetByEmail(email string) (*User, error) {
+	return c.GetByEmailContext(context.Background(), email)
+}
+
+func (c *usersClient) GetByEmailContext(ctx context.Context, email string) (*User, error) {
+	return c.getBy(ctx, "email", email)
+}
+
+func (c *usersClient) GetByUsername(username string) (*User, error) {
+	return c.GetByUsernameContext(context.Background(), username)
+}
+
+func (c *usersClient) GetByUsernameContext(ctx context.Context, username string) (*User, error) {
+	return c.getBy(ctx, "username", username)
+}
+
+func (c *usersClient) GetByEmails(emails []string, concurrency int) []UserResult {
+	return c.GetByEmailsContext(context.Background(), emails, concurrency)
+}
+
+func (c *usersClient) GetByEmailsContext(ctx context.Context, emails []string, concurrency int) []UserResult {
+	return c.getMany(ctx, "email", emails, concurrency)
+}
+
+func (c *usersClient) GetByUsernames(usernames []string, concurrency int) []UserResult {
+	return c.GetByUsernamesContext(context.Background(), usernames, concurrency)
+}
+
+func (c *usersClient) GetByUsernamesContext(ctx context.Context, usernames []string, concurrency int) []UserResult {
+	return c.getMany(ctx, "username", usernames, concurrency)
+}
+
+// DefaultLookupConcurrency is the number of concurrent requests made by
+// GetByEmails and GetByUsernames when none is specified
+const DefaultLookupConcurrency = 8
+
+// UserResult is the result of looking up one user of a batch
+type UserResult struct {
+	// Value is the email or username looked up
+	Value string
+	User  *User
+	Err   error
+}
+
+// getMany looks up many users concurrently, the results are in the order
+// of values.  Once ctx is done, the values not yet looked up have its
+// error.
+func (c *usersClient) getMany(ctx context.Context, field string, values []string, concurrency int) []UserResult {
+	if concurrency <= 0 {
+		concurrency = DefaultLookupConcurrency
+	}
+	rv := make([]UserResult, len(values))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for n := 0; n < concurrency && n < len(values); n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				rv[i].User, rv[i].Err = c.getBy(ctx, field, values[i])
+			}
+		}()
+	}
+	for i, value := range values {
+		rv[i].Value = value
+	}
+dispatch:
+	for i := 0; i < len(values); i++ {
+		select {
+		case work <- i:
+		case <-ctx.Done():
+			for ; i < len(values); i++ {
+				rv[i].Err = ctx.Err()
+			}
+			break dispatch
+		}
+	}
+	close(work)
+	wg.Wait()
+	return rv
+}
+
+func (c *usersClient) login(ctx context.Context, expand []string, login *Login) (*User, *Session, error) {
 	params := make(url.Values)
 	for _, item := range expand {
 		params.Add("expand", item)
@@ -134,7 +283,7 @@ func (c *usersClient) login(expand []string, login *Login) (*User, *Session, err
 	if err != nil {
 		return nil, nil, fmt.Errorf("error marshaling login json: %v", err)
 	}
-	resp, err := c.request(http.MethodPost, "/users/login", params, loginJSON)
+	resp, err := c.request(ctx, "users.login", http.MethodPost, "/users/login", params, loginJSON)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -145,7 +294,11 @@ func (c *usersClient) login(expand []string, login *Login) (*User, *Session, err
 }
 
 func (c *usersClient) LoginWithID(id, password string, expand []string, session *Session) (*User, *Session, error) {
-	return c.login(expand, &Login{
+	return c.LoginWithIDContext(context.Background(), id, password, expand, session)
+}
+
+func (c *usersClient) LoginWithIDContext(ctx context.Context, id, password string, expand []string, session *Session) (*User, *Session, error) {
+	return c.login(ctx, expand, &Login{
 		ID:       id,
 		Password: password,
 		Session:  session,
@@ -153,7 +306,11 @@ func (c *usersClient) LoginWithID(id, password string, expand []string, session
 }
 
 func (c *usersClient) LoginWithEmail(email, password string, expand []string, session *Session) (*User, *Session, error) {
-	return c.login(expand, &Login{
+	return c.LoginWithEmailContext(context.Background(), email, password, expand, session)
+}
+
+func (c *usersClient) LoginWithEmailContext(ctx context.Context, email, password string, expand []string, session *Session) (*User, *Session, error) {
+	return c.login(ctx, expand, &Login{
 		Email:    email,
 		Password: password,
 		Session:  session,
@@ -161,7 +318,11 @@ func (c *usersClient) LoginWithEmail(email, password string, expand []string, se
 }
 
 func (c *usersClient) LoginWithUsername(username, password string, expand []string, session *Session) (*User, *Session, error) {
-	return c.login(expand, &Login{
+	return c.LoginWithUsernameContext(context.Background(), username, password, expand, session)
+}
+
+func (c *usersClient) LoginWithUsernameContext(ctx context.Context, username, password string, expand []string, session *Session) (*User, *Session, error) {
+	return c.login(ctx, expand, &Login{
 		Username: username,
 		Password: password,
 		Session:  session,
@@ -169,7 +330,11 @@ func (c *usersClient) LoginWithUsername(username, password string, expand []stri
 }
 
 func (c *usersClient) LoginWithAny(login, password string, expand []string, session *Session) (*User, *Session, error) {
-	return c.login(expand, &Login{
+	return c.LoginWithAnyContext(context.Background(), login, password, expand, session)
+}
+
+func (c *usersClient) LoginWithAnyContext(ctx context.Context, login, password string, expand []string, session *Session) (*User, *Session, error) {
+	return c.login(ctx, expand, &Login{
 		Login:    login,
 		Password: password,
 		Session:  session,
@@ -177,7 +342,12 @@ func (c *usersClient) LoginWithAny(login, password string, expand []string, sess
 }
 
 func (c *usersClient) DeleteSessions(id string) error {
-	resp, err := c.request(http.MethodDelete, "/users/"+id+"/sessions", nil, nil)
+	return c.DeleteSessionsContext(context.Background(), id)
+}
+
+func (c *usersClient) DeleteSessionsContext(ctx context.Context, id string) error {
+	defer c.sessionCache.InvalidateUser(id)
+	resp, err := c.request(ctx, "users.delete_sessions", http.MethodDelete, "/users/"+id+"/sessions", nil, nil)
 	if err != nil {
 		return err
 	}
@@ -188,11 +358,15 @@ func (c *usersClient) DeleteSessions(id string) error {
 }
 
 func (c *usersClient) ValidatePassword(id, password string) error {
+	return c.ValidatePasswordContext(context.Background(), id, password)
+}
+
+func (c *usersClient) ValidatePasswordContext(ctx context.Context, id, password string) error {
 	validate := map[string]interface{}{
 		"password": password,
 	}
 	validateJSON, err := json.Marshal(validate)
-	resp, err := c.request(http.MethodPost, "/users/"+id+"/password/validate", nil, validateJSON)
+	resp, err := c.request(ctx, "users.validate_password", http.MethodPost, "/users/"+id+"/password/validate", nil, validateJSON)
 	if err != nil {
 		return err
 	}
@@ -203,6 +377,10 @@ func (c *usersClient) ValidatePassword(id, password string) error {
 }
 
 func (c *usersClient) ChangePassword(id, newPassword, currentPassword string, requireCurrent bool) error {
+	return c.ChangePasswordContext(context.Background(), id, newPassword, currentPassword, requireCurrent)
+}
+
+func (c *usersClient) ChangePasswordContext(ctx context.Context, id, newPassword, currentPassword string, requireCurrent bool) error {
 	params := make(url.Values)
 	params.Add("require_current_password", fmt.Sprintf("%t", requireCurrent))
 	change := map[string]interface{}{
@@ -212,7 +390,7 @@ func (c *usersClient) ChangePassword(id, newPassword, currentPassword string, re
 		change["current_password"] = currentPassword
 	}
 	changeJSON, err := json.Marshal(change)
-	resp, err := c.request(http.MethodPost, "/users/"+id+"/password/change", params, changeJSON)
+	resp, err := c.request(ctx, "users.change_password", http.MethodPost, "/users/"+id+"/password/change", params, changeJSON)
 	if err != nil {
 		return err
 	}
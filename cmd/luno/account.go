@@ -0,0 +1,82 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package main
+
+import (
+	"flag"
+
+	"github.com/mschoch/luno-go"
+)
+
+var accountHeader = []string{"id", "email", "username", "name", "created"}
+
+func accountRow(a *luno.Account) []string {
+	return []string{a.ID, a.Email, a.UserName, a.Name, formatTime(a.Created)}
+}
+
+var accountResource = &resource{
+	name: "account",
+	help: "show or update the luno account",
+	commands: []*command{
+		{
+			name: "get",
+			help: "get the account",
+			flags: func(fs *flag.FlagSet) func(e *env, args []string) error {
+				return func(e *env, args []string) error {
+					account, err := e.client.Account.GetContext(e.ctx)
+					if err != nil {
+						return err
+					}
+					return printOne(e.out, account, accountHeader, accountRow)
+				}
+			},
+		},
+		{
+			name: "update",
+			help: "update the fields of the account given as flags",
+			flags: func(fs *flag.FlagSet) func(e *env, args []string) error {
+				email := fs.String("email", "", "email address")
+				name := fs.String("name", "", "full name")
+				firstName := fs.String("first-name", "", "first name")
+				lastName := fs.String("last-name", "", "last name")
+				autoName := fs.Bool("auto-name", false, "derive first and last name from name")
+				return func(e *env, args []string) error {
+					account, err := e.client.Account.GetContext(e.ctx)
+					if err != nil {
+						return err
+					}
+					fs.Visit(func(f *flag.Flag) {
+						switch f.Name {
+						case "email":
+							account.Email = *email
+						case "name":
+							account.Name = *name
+						case "first-name":
+							account.FirstName = *firstName
+						case "last-name":
+							account.LastName = *lastName
+						}
+					})
+					err = e.client.Account.UpdateContext(e.ctx, account, *autoName)
+					if err != nil {
+						return err
+					}
+					account, err = e.client.Account.GetContext(e.ctx)
+					if err != nil {
+						return err
+					}
+					return printOne(e.out, account, accountHeader, accountRow)
+				}
+			},
+		},
+	},
+}
@@ -0,0 +1,97 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"strconv"
+
+	"github.com/mschoch/luno-go"
+)
+
+// aggregateCommand builds a command printing an EntityAggregate
+func aggregateCommand(name, help string, fetch func(c *luno.Client, ctx context.Context, days []string) (luno.EntityAggregate, error)) *command {
+	return &command{
+		name: name,
+		help: help,
+		flags: func(fs *flag.FlagSet) func(e *env, args []string) error {
+			var days stringList
+			fs.Var(&days, "days", "`periods` to count, such as 1, 7 or 30")
+			return func(e *env, args []string) error {
+				aggregate, err := fetch(e.client, e.ctx, days)
+				if err != nil {
+					return err
+				}
+				var rows [][]string
+				for _, key := range sortedKeys(aggregate) {
+					rows = append(rows, []string{key, strconv.Itoa(aggregate[key])})
+				}
+				return e.out.print(aggregate, []string{"period", "count"}, rows)
+			}
+		},
+	}
+}
+
+var analyticsResource = &resource{
+	name: "analytics",
+	help: "show usage analytics",
+	commands: []*command{
+		aggregateCommand("users", "count users", func(c *luno.Client, ctx context.Context, days []string) (luno.EntityAggregate, error) {
+			return c.Analytics.UsersContext(ctx, days)
+		}),
+		aggregateCommand("sessions", "count sessions", func(c *luno.Client, ctx context.Context, days []string) (luno.EntityAggregate, error) {
+			return c.Analytics.SessionsContext(ctx, days)
+		}),
+		aggregateCommand("events", "count events", func(c *luno.Client, ctx context.Context, days []string) (luno.EntityAggregate, error) {
+			return c.Analytics.EventsContext(ctx, days)
+		}),
+		{
+			name: "event-names",
+			help: "list event names with their counts",
+			flags: func(fs *flag.FlagSet) func(e *env, args []string) error {
+				return func(e *env, args []string) error {
+					aggregates, err := e.client.Analytics.EventsListContext(e.ctx)
+					if err != nil {
+						return err
+					}
+					return printList(e.out, aggregates.List, []string{"name", "count", "last"}, func(a *luno.EventAggregate) []string {
+						return []string{a.Name, strconv.Itoa(a.Count), formatTime(a.Last)}
+					})
+				}
+			},
+		},
+		{
+			name: "timeline",
+			help: "count events over time",
+			flags: func(fs *flag.FlagSet) func(e *env, args []string) error {
+				var filter luno.TimelineFilter
+				fs.StringVar(&filter.Name, "name", "", "only events with this name")
+				fs.StringVar(&filter.UserID, "user", "", "only events of the user with this `id`")
+				fs.StringVar(&filter.From, "from", "", "start of the timeline")
+				fs.StringVar(&filter.To, "to", "", "end of the timeline")
+				fs.StringVar(&filter.Group, "group", "", "grouping, such as day or hour")
+				fs.BoolVar(&filter.Distinct, "distinct", false, "count distinct users")
+				fs.BoolVar(&filter.RoundRange, "round-range", false, "round the range to whole groups")
+				return func(e *env, args []string) error {
+					timeline, err := e.client.Analytics.EventsTimelineContext(e.ctx, &filter)
+					if err != nil {
+						return err
+					}
+					return printList(e.out, timeline.Timeline, []string{"timestamp", "count"}, func(t *luno.TimelineEntry) []string {
+						return []string{formatTime(t.Timestamp), strconv.Itoa(t.Count)}
+					})
+				}
+			},
+		},
+	},
+}
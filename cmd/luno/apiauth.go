@@ -0,0 +1,118 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package main
+
+import (
+	"flag"
+
+	"github.com/mschoch/luno-go"
+)
+
+// the secret is only shown in JSON output
+var apiAuthHeader = []string{"id", "user_id", "key", "created", "details"}
+
+func apiAuthRow(a *luno.APIAuth) []string {
+	return []string{a.ID, a.UserID, a.Key, formatTime(a.Created), formatDetails(a.Details)}
+}
+
+var apiAuthResource = &resource{
+	name: "api-auth",
+	help: "manage api authentication keys",
+	commands: []*command{
+		{
+			name: "list",
+			help: "list recent api authentication keys",
+			flags: func(fs *flag.FlagSet) func(e *env, args []string) error {
+				var list listFlags
+				list.register(fs)
+				userID := fs.String("user", "", "only keys of the user with this `id`")
+				return func(e *env, args []string) error {
+					filter := &luno.APIAuthFilter{UserID: *userID}
+					apiAuths, err := collect(e.client.APIAuth.Iter(e.ctx, list.expand, filter, nil), list.limit)
+					if err != nil {
+						return err
+					}
+					return printList(e.out, apiAuths, apiAuthHeader, apiAuthRow)
+				}
+			},
+		},
+		{
+			name: "get",
+			args: "<id>",
+			help: "get an api authentication key by id or key",
+			flags: func(fs *flag.FlagSet) func(e *env, args []string) error {
+				var expand stringList
+				fs.Var(&expand, "expand", "related `entities` to expand, such as user")
+				return func(e *env, args []string) error {
+					apiAuth, err := e.client.APIAuth.GetContext(e.ctx, args[0], expand)
+					if err != nil {
+						return err
+					}
+					return printOne(e.out, apiAuth, apiAuthHeader, apiAuthRow)
+				}
+			},
+		},
+		{
+			name: "create",
+			help: "create an api authentication key",
+			flags: func(fs *flag.FlagSet) func(e *env, args []string) error {
+				userID := fs.String("user", "", "`id` of the user")
+				var details jsonObject
+				fs.Var(&details, "details", "details as a JSON object")
+				return func(e *env, args []string) error {
+					apiAuth := &luno.APIAuth{UserID: *userID, Details: details.details()}
+					apiAuth, err := e.client.APIAuth.CreateContext(e.ctx, apiAuth, nil)
+					if err != nil {
+						return err
+					}
+					return printOne(e.out, apiAuth, apiAuthHeader, apiAuthRow)
+				}
+			},
+		},
+		{
+			name: "update",
+			args: "<id>",
+			help: "update the details of an api authentication key",
+			flags: func(fs *flag.FlagSet) func(e *env, args []string) error {
+				var details jsonObject
+				fs.Var(&details, "details", "details as a JSON object")
+				overwrite := fs.Bool("overwrite-details", false, "replace the details rather than merging")
+				return func(e *env, args []string) error {
+					apiAuth := &luno.APIAuth{Entity: luno.Entity{ID: args[0]}, Details: details.details()}
+					err := e.client.APIAuth.UpdateContext(e.ctx, apiAuth, *overwrite)
+					if err != nil {
+						return err
+					}
+					apiAuth, err = e.client.APIAuth.GetContext(e.ctx, args[0], nil)
+					if err != nil {
+						return err
+					}
+					return printOne(e.out, apiAuth, apiAuthHeader, apiAuthRow)
+				}
+			},
+		},
+		{
+			name: "delete",
+			args: "<id>",
+			help: "delete an api authentication key",
+			flags: func(fs *flag.FlagSet) func(e *env, args []string) error {
+				return func(e *env, args []string) error {
+					err := e.client.APIAuth.DeleteContext(e.ctx, args[0])
+					if err != nil {
+						return err
+					}
+					return e.out.printMessage("deleted api authentication " + args[0])
+				}
+			},
+		},
+	},
+}
@@ -0,0 +1,117 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package main
+
+import (
+	"flag"
+
+	"github.com/mschoch/luno-go"
+)
+
+var eventHeader = []string{"id", "user_id", "name", "timestamp", "details"}
+
+func eventRow(ev *luno.Event) []string {
+	return []string{ev.ID, ev.UserID, ev.Name, formatTime(ev.Timestamp), formatDetails(ev.Details)}
+}
+
+var eventsResource = &resource{
+	name: "events",
+	help: "manage events",
+	commands: []*command{
+		{
+			name: "list",
+			help: "list recent events",
+			flags: func(fs *flag.FlagSet) func(e *env, args []string) error {
+				var list listFlags
+				list.register(fs)
+				userID := fs.String("user", "", "only events of the user with this `id`")
+				name := fs.String("name", "", "only events with this name")
+				return func(e *env, args []string) error {
+					filter := &luno.EventFilter{UserID: *userID, Name: *name}
+					events, err := collect(e.client.Events.Iter(e.ctx, list.expand, filter, nil), list.limit)
+					if err != nil {
+						return err
+					}
+					return printList(e.out, events, eventHeader, eventRow)
+				}
+			},
+		},
+		{
+			name: "get",
+			args: "<id>",
+			help: "get an event by id",
+			flags: func(fs *flag.FlagSet) func(e *env, args []string) error {
+				return func(e *env, args []string) error {
+					event, err := e.client.Events.GetContext(e.ctx, args[0])
+					if err != nil {
+						return err
+					}
+					return printOne(e.out, event, eventHeader, eventRow)
+				}
+			},
+		},
+		{
+			name: "create",
+			help: "create an event",
+			flags: func(fs *flag.FlagSet) func(e *env, args []string) error {
+				name := fs.String("name", "", "name of the event")
+				userID := fs.String("user", "", "`id` of the user")
+				var details jsonObject
+				fs.Var(&details, "details", "details as a JSON object")
+				return func(e *env, args []string) error {
+					event := &luno.Event{Name: *name, UserID: *userID, Details: details.details()}
+					event, err := e.client.Events.CreateContext(e.ctx, event, nil)
+					if err != nil {
+						return err
+					}
+					return printOne(e.out, event, eventHeader, eventRow)
+				}
+			},
+		},
+		{
+			name: "update",
+			args: "<id>",
+			help: "update the details of an event",
+			flags: func(fs *flag.FlagSet) func(e *env, args []string) error {
+				var details jsonObject
+				fs.Var(&details, "details", "details as a JSON object")
+				overwrite := fs.Bool("overwrite-details", false, "replace the details rather than merging")
+				return func(e *env, args []string) error {
+					event := &luno.Event{Entity: luno.Entity{ID: args[0]}, Details: details.details()}
+					err := e.client.Events.UpdateContext(e.ctx, event, *overwrite)
+					if err != nil {
+						return err
+					}
+					event, err = e.client.Events.GetContext(e.ctx, args[0])
+					if err != nil {
+						return err
+					}
+					return printOne(e.out, event, eventHeader, eventRow)
+				}
+			},
+		},
+		{
+			name: "delete",
+			args: "<id>",
+			help: "delete an event",
+			flags: func(fs *flag.FlagSet) func(e *env, args []string) error {
+				return func(e *env, args []string) error {
+					err := e.client.Events.DeleteContext(e.ctx, args[0])
+					if err != nil {
+						return err
+					}
+					return e.out.printMessage("deleted event " + args[0])
+				}
+			},
+		},
+	},
+}
@@ -0,0 +1,273 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+// Command luno is a command-line client for the Luno API.
+//
+// Usage:
+//
+//	luno [-config file] [-profile name] [-output json|table|csv] <resource> <command> [flags] [args]
+//
+// The resources are users, sessions, events, api-auth, analytics and
+// account, run "luno <resource>" for their commands.  Credentials are read
+// from the environment, a secrets file or a profile of the config file,
+// see luno.ConfigLoader.  Lists are paged automatically.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+
+	"github.com/mschoch/luno-go"
+)
+
+// errUsage is returned when the command line is invalid, the usage has
+// already been printed
+var errUsage = errors.New("invalid usage")
+
+// command is a subcommand of a resource
+type command struct {
+	name  string
+	args  string
+	help  string
+	flags func(fs *flag.FlagSet) func(env *env, args []string) error
+}
+
+// resource is a group of commands, such as users
+type resource struct {
+	name     string
+	help     string
+	commands []*command
+}
+
+var resources = []*resource{
+	usersResource,
+	sessionsResource,
+	eventsResource,
+	apiAuthResource,
+	analyticsResource,
+	accountResource,
+}
+
+// env is what a command runs with
+type env struct {
+	ctx    context.Context
+	client *luno.Client
+	stdin  io.Reader
+	out    *printer
+	stderr io.Writer
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	err := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr, os.Getenv)
+	stop()
+	if err != nil {
+		if err != errUsage {
+			_, _ = fmt.Fprintf(os.Stderr, "luno: %v\n", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer, getenv func(string) string) error {
+	fs := flag.NewFlagSet("luno", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	configPath := fs.String("config", "", "config `file`, default $LUNO_CONFIG or ~/.config/luno/config")
+	profile := fs.String("profile", "", "config `profile`, default $LUNO_PROFILE or default")
+	output := fs.String("output", "table", "output `format`, json, table or csv")
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(stderr, "usage: luno [flags] <resource> <command> [flags] [args]\n\nresources:\n")
+		for _, r := range resources {
+			_, _ = fmt.Fprintf(stderr, "  %-10s %s\n", r.name, r.help)
+		}
+		_, _ = fmt.Fprintf(stderr, "\nflags:\n")
+		fs.PrintDefaults()
+	}
+	err := fs.Parse(args)
+	if err != nil {
+		return errUsage
+	}
+	out, err := newPrinter(stdout, *output)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+	res := findResource(fs.Arg(0))
+	if res == nil {
+		_, _ = fmt.Fprintf(stderr, "unknown resource %q\n", fs.Arg(0))
+		fs.Usage()
+		return errUsage
+	}
+	if fs.NArg() == 1 {
+		res.usage(stderr)
+		return errUsage
+	}
+	cmd := res.find(fs.Arg(1))
+	if cmd == nil {
+		_, _ = fmt.Fprintf(stderr, "unknown command %q\n", fs.Arg(1))
+		res.usage(stderr)
+		return errUsage
+	}
+
+	cmdFlags := flag.NewFlagSet("luno "+res.name+" "+cmd.name, flag.ContinueOnError)
+	cmdFlags.SetOutput(stderr)
+	cmdFlags.Usage = func() {
+		_, _ = fmt.Fprintf(stderr, "usage: luno %s %s [flags] %s\n\n%s\n", res.name, cmd.name, cmd.args, cmd.help)
+		cmdFlags.PrintDefaults()
+	}
+	runCmd := cmd.flags(cmdFlags)
+	positional, err := parseInterspersed(cmdFlags, fs.Args()[2:])
+	if err != nil {
+		return errUsage
+	}
+	if want := len(strings.Fields(cmd.args)); len(positional) != want {
+		cmdFlags.Usage()
+		return errUsage
+	}
+
+	loader := luno.ConfigLoader{Path: *configPath, Profile: *profile, Getenv: getenv}
+	cfg, err := loader.Load()
+	if err != nil {
+		return err
+	}
+	return runCmd(&env{
+		ctx:    ctx,
+		client: cfg.NewClient(),
+		stdin:  stdin,
+		out:    out,
+		stderr: stderr,
+	}, positional)
+}
+
+// parseInterspersed parses flags which may appear before, between or after
+// the positional arguments, returning the positional arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		err := fs.Parse(args)
+		if err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+func findResource(name string) *resource {
+	for _, r := range resources {
+		if r.name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+func (r *resource) find(name string) *command {
+	for _, c := range r.commands {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func (r *resource) usage(w io.Writer) {
+	_, _ = fmt.Fprintf(w, "usage: luno %s <command> [flags] [args]\n\ncommands:\n", r.name)
+	for _, c := range r.commands {
+		_, _ = fmt.Fprintf(w, "  %-16s %s\n", strings.TrimSpace(c.name+" "+c.args), c.help)
+	}
+}
+
+// stringList is a flag which may be repeated, or given a comma separated
+// list
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(v string) error {
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*s = append(*s, item)
+		}
+	}
+	return nil
+}
+
+// jsonObject is a flag holding a JSON object, such as details or a profile
+type jsonObject struct {
+	value map[string]interface{}
+}
+
+func (j *jsonObject) String() string {
+	if j == nil || j.value == nil {
+		return ""
+	}
+	data, _ := json.Marshal(j.value)
+	return string(data)
+}
+
+func (j *jsonObject) Set(v string) error {
+	return json.Unmarshal([]byte(v), &j.value)
+}
+
+// details returns the value as untyped details, nil if it was not set
+func (j *jsonObject) details() interface{} {
+	if j.value == nil {
+		return nil
+	}
+	return j.value
+}
+
+// listFlags are the flags shared by list commands
+type listFlags struct {
+	limit  int
+	expand stringList
+}
+
+func (l *listFlags) register(fs *flag.FlagSet) {
+	fs.IntVar(&l.limit, "limit", 100, "maximum number of items, 0 for all")
+	fs.Var(&l.expand, "expand", "related `entities` to expand, such as user")
+}
+
+// collect reads up to limit items from an iterator
+func collect[T any](it *luno.Iterator[T], limit int) ([]T, error) {
+	var rv []T
+	for it = it.Max(limit); it.Next(); {
+		rv = append(rv, it.Value())
+	}
+	return rv, it.Err()
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys[V any](m map[string]V) []string {
+	rv := make([]string, 0, len(m))
+	for k := range m {
+		rv = append(rv, k)
+	}
+	sort.Strings(rv)
+	return rv
+}
@@ -0,0 +1,220 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mschoch/luno-go"
+	"github.com/mschoch/luno-go/lunotest"
+)
+
+// runLuno runs the command line against a fake server, returning stdout
+func runLuno(t *testing.T, s *lunotest.Server, args ...string) (string, error) {
+	t.Helper()
+	return runLunoStdin(t, s, "", args...)
+}
+
+// runLunoStdin is runLuno with the provided stdin
+func runLunoStdin(t *testing.T, s *lunotest.Server, stdin string, args ...string) (string, error) {
+	t.Helper()
+	getenv := func(name string) string {
+		switch name {
+		case "LUNO_API_KEY":
+			return s.APIKey
+		case "LUNO_SECRET_KEY":
+			return s.SecretKey
+		case "LUNO_BASE_URL":
+			return s.URL
+		}
+		return ""
+	}
+	var stdout, stderr bytes.Buffer
+	err := run(context.Background(), args, strings.NewReader(stdin), &stdout, &stderr, getenv)
+	return stdout.String(), err
+}
+
+func TestUsers(t *testing.T) {
+	s := lunotest.NewServer()
+	defer s.Close()
+
+	out, err := runLunoStdin(t, s, "secret123\n", "-output", "json", "-profile", "default", "users", "create",
+		"-email", "marty@example.com", "-name", "Marty Schoch", "-auto-name", "-password-stdin", "-profile-json", `{"plan":"free"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var user luno.User
+	err = json.Unmarshal([]byte(out), &user)
+	if err != nil {
+		t.Fatalf("error parsing %q: %v", out, err)
+	}
+	if user.ID == "" || user.FirstName != "Marty" {
+		t.Fatalf("unexpected user %+v", user)
+	}
+
+	out, err = runLuno(t, s, "-output", "json", "users", "update", user.ID, "-username", "marty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var updated luno.User
+	err = json.Unmarshal([]byte(out), &updated)
+	if err != nil {
+		t.Fatalf("error parsing %q: %v", out, err)
+	}
+	if updated.UserName != "marty" || updated.Email != "marty@example.com" {
+		t.Errorf("update lost fields %+v", updated)
+	}
+
+	_, _, err = s.Client().Users.LoginWithEmail("marty@example.com", "secret123", nil, nil)
+	if err != nil {
+		t.Errorf("expected password read from stdin, got %v", err)
+	}
+	_, err = runLunoStdin(t, s, "\n", "users", "change-password", user.ID)
+	if err == nil || !strings.Contains(err.Error(), "empty password") {
+		t.Errorf("expected empty password to be rejected, got %v", err)
+	}
+	_, err = runLunoStdin(t, s, "newsecret\nwrong\n", "users", "change-password", "-require-current", user.ID)
+	if !errors.Is(err, luno.ErrIncorrectPassword) {
+		t.Errorf("expected incorrect current password, got %v", err)
+	}
+	_, err = runLunoStdin(t, s, "newsecret\nsecret123\n", "users", "change-password", "-require-current", user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = s.Client().Users.LoginWithEmail("marty@example.com", "newsecret", nil, nil)
+	if err != nil {
+		t.Errorf("expected changed password, got %v", err)
+	}
+
+	out, err = runLuno(t, s, "users", "list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "ID") || !strings.Contains(lines[1], user.ID) {
+		t.Errorf("unexpected table %q", out)
+	}
+
+	out, err = runLuno(t, s, "-output", "csv", "users", "get", user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out, "id,email,username,name,created,closed\n"+user.ID+",marty@example.com,marty,") {
+		t.Errorf("unexpected csv %q", out)
+	}
+
+	_, err = runLuno(t, s, "users", "delete", user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = runLuno(t, s, "users", "get", user.ID)
+	if !luno.IsNotFound(err) {
+		t.Errorf("expected not found, got %v", err)
+	}
+}
+
+func TestSessionsAndEvents(t *testing.T) {
+	s := lunotest.NewServer()
+	defer s.Close()
+
+	out, err := runLuno(t, s, "-output", "json", "sessions", "create", "-ip", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var session luno.Session
+	err = json.Unmarshal([]byte(out), &session)
+	if err != nil {
+		t.Fatalf("error parsing %q: %v", out, err)
+	}
+	out, err = runLuno(t, s, "sessions", "access", session.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, session.ID) {
+		t.Errorf("unexpected output %q", out)
+	}
+
+	_, err = runLuno(t, s, "events", "create", "-name", "signup", "-details", `{"source":"cli"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err = runLuno(t, s, "events", "list", "-name", "signup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "signup") || !strings.Contains(out, `{"source":"cli"}`) {
+		t.Errorf("unexpected output %q", out)
+	}
+
+	out, err = runLuno(t, s, "analytics", "event-names")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "signup") {
+		t.Errorf("unexpected output %q", out)
+	}
+}
+
+func TestProfile(t *testing.T) {
+	s := lunotest.NewServer()
+	defer s.Close()
+
+	path := filepath.Join(t.TempDir(), "config")
+	config := fmt.Sprintf("[test]\napi_key = %q\nsecret_key = %q\nbase_url = %q\n", s.APIKey, s.SecretKey, s.URL)
+	err := os.WriteFile(path, []byte(config), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	noenv := func(string) string { return "" }
+	err = run(context.Background(), []string{"-config", path, "-profile", "test", "account", "get"}, nil, &stdout, &stderr, noenv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout.String(), "owner@example.com") {
+		t.Errorf("unexpected output %q", stdout.String())
+	}
+
+	err = run(context.Background(), []string{"-config", path, "-profile", "prod", "account", "get"}, nil, &stdout, &stderr, noenv)
+	if err == nil || !strings.Contains(err.Error(), "profile 'prod' not found") {
+		t.Errorf("expected missing profile error, got %v", err)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	s := lunotest.NewServer()
+	defer s.Close()
+
+	tests := [][]string{
+		nil,
+		{"widgets"},
+		{"users"},
+		{"users", "frobnicate"},
+		{"users", "get"},
+		{"users", "get", "a", "b"},
+		{"-output", "yaml", "account", "get"},
+	}
+	for _, args := range tests {
+		_, err := runLuno(t, s, args...)
+		if err == nil {
+			t.Errorf("expected error for %q", args)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mschoch/luno-go"
+)
+
+// printer writes results in the selected output format
+type printer struct {
+	w      io.Writer
+	format string
+}
+
+func newPrinter(w io.Writer, format string) (*printer, error) {
+	switch format {
+	case "json", "table", "csv":
+		return &printer{w: w, format: format}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q, use json, table or csv", format)
+}
+
+// print writes v as JSON, or the header and rows as a table or CSV
+func (p *printer) print(v interface{}, header []string, rows [][]string) error {
+	switch p.format {
+	case "json":
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshaling json: %v", err)
+		}
+		_, err = fmt.Fprintf(p.w, "%s\n", data)
+		return err
+	case "csv":
+		w := csv.NewWriter(p.w)
+		_ = w.Write(header)
+		_ = w.WriteAll(rows)
+		return w.Error()
+	}
+	w := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, strings.ToUpper(strings.Join(header, "\t")))
+	for _, row := range rows {
+		_, _ = fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+// printMessage writes the result of a command with no other output, such
+// as a delete
+func (p *printer) printMessage(msg string) error {
+	return p.print(map[string]interface{}{"success": true, "message": msg}, []string{"result"}, [][]string{{msg}})
+}
+
+// printList writes a list of items, using row to build the table or CSV
+// rows
+func printList[T any](p *printer, items []T, header []string, row func(T) []string) error {
+	if items == nil {
+		items = []T{}
+	}
+	rows := make([][]string, 0, len(items))
+	for _, item := range items {
+		rows = append(rows, row(item))
+	}
+	return p.print(items, header, rows)
+}
+
+// printOne writes a single item, using row to build the table or CSV row
+func printOne[T any](p *printer, item T, header []string, row func(T) []string) error {
+	return p.print(item, header, [][]string{row(item)})
+}
+
+// formatTime formats a time for tables, zero times are empty
+func formatTime(t luno.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.String()
+}
+
+// formatDetails formats untyped details for tables
+func formatDetails(details interface{}) string {
+	if details == nil {
+		return ""
+	}
+	data, err := json.Marshal(details)
+	if err != nil {
+		return fmt.Sprintf("%v", details)
+	}
+	return string(data)
+}
@@ -0,0 +1,135 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"strconv"
+
+	"github.com/mschoch/luno-go"
+)
+
+var sessionHeader = []string{"id", "user_id", "created", "expires", "last_access", "access_count", "ip", "user_agent"}
+
+func sessionRow(s *luno.Session) []string {
+	return []string{s.ID, s.UserID, formatTime(s.Created), formatTime(s.Expires), formatTime(s.LastAccess),
+		strconv.Itoa(s.AccessCount), s.IP, s.UserAgent}
+}
+
+var sessionsResource = &resource{
+	name: "sessions",
+	help: "manage sessions",
+	commands: []*command{
+		{
+			name: "list",
+			help: "list recent sessions",
+			flags: func(fs *flag.FlagSet) func(e *env, args []string) error {
+				var list listFlags
+				list.register(fs)
+				userID := fs.String("user", "", "only sessions of the user with this `id`")
+				return func(e *env, args []string) error {
+					filter := &luno.SessionFilter{UserID: *userID}
+					sessions, err := collect(e.client.Sessions.Iter(e.ctx, list.expand, filter, nil), list.limit)
+					if err != nil {
+						return err
+					}
+					return printList(e.out, sessions, sessionHeader, sessionRow)
+				}
+			},
+		},
+		{
+			name: "get",
+			args: "<id>",
+			help: "get a session by id",
+			flags: func(fs *flag.FlagSet) func(e *env, args []string) error {
+				return func(e *env, args []string) error {
+					session, err := e.client.Sessions.GetContext(e.ctx, args[0])
+					if err != nil {
+						return err
+					}
+					return printOne(e.out, session, sessionHeader, sessionRow)
+				}
+			},
+		},
+		{
+			name: "create",
+			help: "create a session",
+			flags: func(fs *flag.FlagSet) func(e *env, args []string) error {
+				userID := fs.String("user", "", "`id` of the user, empty for an anonymous session")
+				ip := fs.String("ip", "", "client IP address")
+				userAgent := fs.String("user-agent", "", "client User-Agent")
+				var details jsonObject
+				fs.Var(&details, "details", "details as a JSON object")
+				return func(e *env, args []string) error {
+					session := &luno.Session{UserID: *userID, IP: *ip, UserAgent: *userAgent, Details: details.details()}
+					session, err := e.client.Sessions.CreateContext(e.ctx, session, nil)
+					if err != nil {
+						return err
+					}
+					return printOne(e.out, session, sessionHeader, sessionRow)
+				}
+			},
+		},
+		{
+			name: "update",
+			args: "<id>",
+			help: "update the details of a session",
+			flags: func(fs *flag.FlagSet) func(e *env, args []string) error {
+				var details jsonObject
+				fs.Var(&details, "details", "details as a JSON object")
+				overwrite := fs.Bool("overwrite-details", false, "replace the details rather than merging")
+				return func(e *env, args []string) error {
+					session := &luno.Session{Entity: luno.Entity{ID: args[0]}, Details: details.details()}
+					err := e.client.Sessions.UpdateContext(e.ctx, session, *overwrite)
+					if err != nil {
+						return err
+					}
+					session, err = e.client.Sessions.GetContext(e.ctx, args[0])
+					if err != nil {
+						return err
+					}
+					return printOne(e.out, session, sessionHeader, sessionRow)
+				}
+			},
+		},
+		{
+			name: "delete",
+			args: "<id>",
+			help: "delete a session",
+			flags: func(fs *flag.FlagSet) func(e *env, args []string) error {
+				return func(e *env, args []string) error {
+					err := e.client.Sessions.DeleteContext(e.ctx, args[0])
+					if err != nil {
+						return err
+					}
+					return e.out.printMessage("deleted session " + args[0])
+				}
+			},
+		},
+		{
+			name: "access",
+			args: "<key>",
+			help: "check a session key, recording an access",
+			flags: func(fs *flag.FlagSet) func(e *env, args []string) error {
+				var expand stringList
+				fs.Var(&expand, "expand", "related `entities` to expand, such as user")
+				return func(e *env, args []string) error {
+					session, err := e.client.Sessions.AccessContext(e.ctx, &luno.Session{Key: args[0]}, expand)
+					if err != nil {
+						return err
+					}
+					return printOne(e.out, session, sessionHeader, sessionRow)
+				}
+			},
+		},
+	},
+}
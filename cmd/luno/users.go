@@ -0,0 +1,258 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mschoch/luno-go"
+)
+
+var userHeader = []string{"id", "email", "username", "name", "created", "closed"}
+
+func userRow(u *luno.User) []string {
+	return []string{u.ID, u.Email, u.UserName, u.Name, formatTime(u.Created), formatTime(u.Closed)}
+}
+
+// userFields are the flags for the editable fields of a user
+type userFields struct {
+	email, username, name, firstName, lastName string
+	profile                                    jsonObject
+}
+
+func (u *userFields) register(fs *flag.FlagSet) {
+	fs.StringVar(&u.email, "email", "", "email address")
+	fs.StringVar(&u.username, "username", "", "username")
+	fs.StringVar(&u.name, "name", "", "full name")
+	fs.StringVar(&u.firstName, "first-name", "", "first name")
+	fs.StringVar(&u.lastName, "last-name", "", "last name")
+	fs.Var(&u.profile, "profile-json", "profile as a JSON object")
+}
+
+// apply sets the fields given on the command line
+func (u *userFields) apply(fs *flag.FlagSet, user *luno.User) {
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "email":
+			user.Email = u.email
+		case "username":
+			user.UserName = u.username
+		case "name":
+			user.Name = u.name
+		case "first-name":
+			user.FirstName = u.firstName
+		case "last-name":
+			user.LastName = u.lastName
+		case "profile-json":
+			user.Profile = u.profile.details()
+		}
+	})
+}
+
+var usersResource = &resource{
+	name: "users",
+	help: "manage users",
+	commands: []*command{
+		{
+			name: "list",
+			help: "list recent users",
+			flags: func(fs *flag.FlagSet) func(e *env, args []string) error {
+				var list listFlags
+				list.register(fs)
+				return func(e *env, args []string) error {
+					users, err := collect(e.client.Users.Iter(e.ctx, list.expand, nil), list.limit)
+					if err != nil {
+						return err
+					}
+					return printList(e.out, users, userHeader, userRow)
+				}
+			},
+		},
+		{
+			name: "get",
+			args: "<id>",
+			help: "get a user by id",
+			flags: func(fs *flag.FlagSet) func(e *env, args []string) error {
+				return func(e *env, args []string) error {
+					user, err := e.client.Users.GetContext(e.ctx, args[0])
+					if err != nil {
+						return err
+					}
+					return printOne(e.out, user, userHeader, userRow)
+				}
+			},
+		},
+		{
+			name: "create",
+			help: "create a user",
+			flags: func(fs *flag.FlagSet) func(e *env, args []string) error {
+				var fields userFields
+				fields.register(fs)
+				passwordStdin := fs.Bool("password-stdin", false, "read the password from the first line of stdin")
+				autoName := fs.Bool("auto-name", false, "derive first and last name from name")
+				return func(e *env, args []string) error {
+					user := &luno.User{}
+					fields.apply(fs, user)
+					if *passwordStdin {
+						passwords, err := readPasswords(e.stdin, 1)
+						if err != nil {
+							return err
+						}
+						user.Password = passwords[0]
+					}
+					user, err := e.client.Users.CreateContext(e.ctx, user, *autoName, nil)
+					if err != nil {
+						return err
+					}
+					return printOne(e.out, user, userHeader, userRow)
+				}
+			},
+		},
+		{
+			name: "update",
+			args: "<id>",
+			help: "update the fields of a user given as flags",
+			flags: func(fs *flag.FlagSet) func(e *env, args []string) error {
+				var fields userFields
+				fields.register(fs)
+				autoName := fs.Bool("auto-name", false, "derive first and last name from name")
+				overwrite := fs.Bool("overwrite-profile", false, "replace the profile rather than merging")
+				return func(e *env, args []string) error {
+					user, err := e.client.Users.GetContext(e.ctx, args[0])
+					if err != nil {
+						return err
+					}
+					fields.apply(fs, user)
+					err = e.client.Users.UpdateContext(e.ctx, user, *autoName, *overwrite)
+					if err != nil {
+						return err
+					}
+					user, err = e.client.Users.GetContext(e.ctx, args[0])
+					if err != nil {
+						return err
+					}
+					return printOne(e.out, user, userHeader, userRow)
+				}
+			},
+		},
+		{
+			name: "deactivate",
+			args: "<id>",
+			help: "close a user, it can be reactivated",
+			flags: func(fs *flag.FlagSet) func(e *env, args []string) error {
+				return func(e *env, args []string) error {
+					err := e.client.Users.DeactivateContext(e.ctx, args[0])
+					if err != nil {
+						return err
+					}
+					return e.out.printMessage("deactivated user " + args[0])
+				}
+			},
+		},
+		{
+			name: "reactivate",
+			args: "<id>",
+			help: "reopen a closed user",
+			flags: func(fs *flag.FlagSet) func(e *env, args []string) error {
+				return func(e *env, args []string) error {
+					err := e.client.Users.ReactivateContext(e.ctx, args[0])
+					if err != nil {
+						return err
+					}
+					return e.out.printMessage("reactivated user " + args[0])
+				}
+			},
+		},
+		{
+			name: "delete",
+			args: "<id>",
+			help: "permanently delete a user",
+			flags: func(fs *flag.FlagSet) func(e *env, args []string) error {
+				return func(e *env, args []string) error {
+					err := e.client.Users.DeleteContext(e.ctx, args[0])
+					if err != nil {
+						return err
+					}
+					return e.out.printMessage("deleted user " + args[0])
+				}
+			},
+		},
+		{
+			name: "change-password",
+			args: "<id>",
+			help: "change the password of a user, read from the first line of stdin",
+			flags: func(fs *flag.FlagSet) func(e *env, args []string) error {
+				requireCurrent := fs.Bool("require-current", false, "require the current password, read from the second line of stdin")
+				return func(e *env, args []string) error {
+					n := 1
+					if *requireCurrent {
+						n = 2
+					}
+					passwords, err := readPasswords(e.stdin, n)
+					if err != nil {
+						return err
+					}
+					var current string
+					if *requireCurrent {
+						current = passwords[1]
+					}
+					err = e.client.Users.ChangePasswordContext(e.ctx, args[0], passwords[0], current, *requireCurrent)
+					if err != nil {
+						return err
+					}
+					return e.out.printMessage("changed password of user " + args[0])
+				}
+			},
+		},
+		{
+			name: "delete-sessions",
+			args: "<id>",
+			help: "delete all sessions of a user",
+			flags: func(fs *flag.FlagSet) func(e *env, args []string) error {
+				return func(e *env, args []string) error {
+					err := e.client.Users.DeleteSessionsContext(e.ctx, args[0])
+					if err != nil {
+						return err
+					}
+					return e.out.printMessage("deleted sessions of user " + args[0])
+				}
+			},
+		},
+	},
+}
+
+// readPasswords reads n passwords, one per line, from r.  Passwords are
+// never taken from flags, where they would be visible to other users of
+// the system and kept in shell history.
+func readPasswords(r io.Reader, n int) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	rv := make([]string, 0, n)
+	for len(rv) < n && scanner.Scan() {
+		password := strings.TrimSuffix(scanner.Text(), "\r")
+		if password == "" {
+			return nil, errors.New("empty password on stdin")
+		}
+		rv = append(rv, password)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading password: %v", err)
+	}
+	if len(rv) < n {
+		return nil, fmt.Errorf("expected %d password lines on stdin, got %d", n, len(rv))
+	}
+	return rv, nil
+}
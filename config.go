@@ -0,0 +1,417 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package luno
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Environment variables read by ConfigLoader
+const (
+	EnvAPIKey      = "LUNO_API_KEY"
+	EnvSecretKey   = "LUNO_SECRET_KEY"
+	EnvBaseURL     = "LUNO_BASE_URL"
+	EnvProfile     = "LUNO_PROFILE"
+	EnvConfigFile  = "LUNO_CONFIG"
+	EnvSecretsFile = "LUNO_SECRETS_FILE"
+)
+
+// DefaultProfile is the profile used when none is selected, in a config
+// file it also holds the keys which appear before any profile
+const DefaultProfile = "default"
+
+// Config holds the credentials and endpoint used to build a Client
+type Config struct {
+	APIKey    string
+	SecretKey string
+	// BaseURL is optional, the default is the public Luno API
+	BaseURL string
+	// Profile is the name of the profile which was loaded
+	Profile string
+}
+
+// Validate checks that the credentials are present and the base URL is
+// valid
+func (c *Config) Validate() error {
+	if c.APIKey == "" || c.SecretKey == "" {
+		return fmt.Errorf("invalid luno config for profile '%s': api_key and secret_key required", c.Profile)
+	}
+	if c.BaseURL != "" {
+		_, err := parseBaseURL(c.BaseURL)
+		if err != nil {
+			return fmt.Errorf("invalid luno config for profile '%s': %v", c.Profile, err)
+		}
+	}
+	return nil
+}
+
+// NewClient builds a Client from the config, additional options are
+// applied after the base URL
+func (c *Config) NewClient(opts ...Option) *Client {
+	if c.BaseURL != "" {
+		opts = append([]Option{WithBaseURL(c.BaseURL)}, opts...)
+	}
+	return NewClient(c.APIKey, c.SecretKey, opts...)
+}
+
+// ConfigLoader resolves a Config from the environment, a secrets file and
+// a config file of named profiles.
+//
+// The config file is TOML or JSON.  Each table, or object, is a profile
+// with the keys api_key, secret_key, base_url and secrets_file, keys before
+// the first table belong to the default profile:
+//
+//	api_key = "..."
+//	secret_key = "..."
+//
+//	[staging]
+//	base_url = "https://luno.staging.example.com"
+//	secrets_file = "/run/secrets/luno-staging"
+//
+// A secrets file has the same format without profiles, so that credentials
+// can be kept apart from the rest of the config.
+//
+// The credentials come from the first of LUNO_API_KEY and LUNO_SECRET_KEY,
+// which must be set together, the secrets file, and the profile.  The base
+// URL comes from the first of LUNO_BASE_URL, the secrets file and the
+// profile.  A secrets file only overrides the keys it contains, so it may
+// hold just the secret key.
+type ConfigLoader struct {
+	// Path is the config file, the default is LUNO_CONFIG, or
+	// DefaultConfigPath if that exists
+	Path string
+	// Profile selects the profile, the default is LUNO_PROFILE or
+	// DefaultProfile.  A profile other than the default must exist.
+	Profile string
+	// SecretsFile is read for credentials, the default is
+	// LUNO_SECRETS_FILE, or the secrets_file of the profile
+	SecretsFile string
+	// Getenv reads the environment, the default is os.Getenv
+	Getenv func(string) string
+}
+
+// DefaultConfigPath returns the default config file,
+// luno/config in the user config directory such as ~/.config
+func DefaultConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "luno", "config"), nil
+}
+
+// LoadConfig loads the Config using the defaults of ConfigLoader
+func LoadConfig() (*Config, error) {
+	return ConfigLoader{}.Load()
+}
+
+// LoadClient loads the Config using the defaults of ConfigLoader and
+// builds a Client from it
+func LoadClient(opts ...Option) (*Client, error) {
+	config, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	return config.NewClient(opts...), nil
+}
+
+// Load resolves and validates the Config
+func (l ConfigLoader) Load() (*Config, error) {
+	getenv := l.Getenv
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+	rv := &Config{Profile: l.Profile}
+	if rv.Profile == "" {
+		rv.Profile = getenv(EnvProfile)
+	}
+	explicitProfile := rv.Profile != "" && rv.Profile != DefaultProfile
+	if rv.Profile == "" {
+		rv.Profile = DefaultProfile
+	}
+
+	profile, configDir, err := l.loadProfile(rv.Profile, explicitProfile, getenv)
+	if err != nil {
+		return nil, err
+	}
+	rv.APIKey = profile["api_key"]
+	rv.SecretKey = profile["secret_key"]
+	rv.BaseURL = profile["base_url"]
+
+	secretsFile := l.SecretsFile
+	if secretsFile == "" {
+		secretsFile = getenv(EnvSecretsFile)
+	}
+	if secretsFile == "" && profile["secrets_file"] != "" {
+		secretsFile = profile["secrets_file"]
+		if !filepath.IsAbs(secretsFile) {
+			secretsFile = filepath.Join(configDir, secretsFile)
+		}
+	}
+	if secretsFile != "" {
+		secrets, err := readSecretsFile(secretsFile)
+		if err != nil {
+			return nil, err
+		}
+		if secrets["api_key"] != "" {
+			rv.APIKey = secrets["api_key"]
+		}
+		if secrets["secret_key"] != "" {
+			rv.SecretKey = secrets["secret_key"]
+		}
+		if secrets["base_url"] != "" {
+			rv.BaseURL = secrets["base_url"]
+		}
+	}
+
+	apiKey, secretKey := getenv(EnvAPIKey), getenv(EnvSecretKey)
+	if apiKey != "" || secretKey != "" {
+		if apiKey == "" || secretKey == "" {
+			return nil, fmt.Errorf("invalid luno config: %s and %s must be set together", EnvAPIKey, EnvSecretKey)
+		}
+		rv.APIKey = apiKey
+		rv.SecretKey = secretKey
+	}
+	if baseURL := getenv(EnvBaseURL); baseURL != "" {
+		rv.BaseURL = baseURL
+	}
+
+	err = rv.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
+
+// loadProfile reads the named profile from the config file, returning it
+// with the directory of the file
+func (l ConfigLoader) loadProfile(name string, required bool, getenv func(string) string) (map[string]string, string, error) {
+	path := l.Path
+	if path == "" {
+		path = getenv(EnvConfigFile)
+	}
+	explicitPath := path != ""
+	if !explicitPath {
+		var err error
+		path, err = DefaultConfigPath()
+		if err != nil && required {
+			return nil, "", fmt.Errorf("error finding luno config: %v", err)
+		}
+	}
+	var profiles map[string]map[string]string
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil && (explicitPath || !os.IsNotExist(err)) {
+			return nil, "", fmt.Errorf("error reading luno config: %v", err)
+		}
+		if err == nil {
+			profiles, err = parseConfig(data, true)
+			if err != nil {
+				return nil, "", fmt.Errorf("error parsing luno config %s: %v", path, err)
+			}
+		}
+	}
+	profile, ok := profiles[name]
+	if !ok && required {
+		return nil, "", fmt.Errorf("luno config profile '%s' not found in %s", name, path)
+	}
+	return profile, filepath.Dir(path), nil
+}
+
+func readSecretsFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading luno secrets file: %v", err)
+	}
+	profiles, err := parseConfig(data, false)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing luno secrets file %s: %v", path, err)
+	}
+	secrets := profiles[DefaultProfile]
+	if secrets["secrets_file"] != "" {
+		return nil, fmt.Errorf("error parsing luno secrets file %s: secrets_file not allowed", path)
+	}
+	return secrets, nil
+}
+
+var configKeys = map[string]bool{
+	"api_key":      true,
+	"secret_key":   true,
+	"base_url":     true,
+	"secrets_file": true,
+}
+
+// parseConfig parses a config or secrets file, JSON if it starts with an
+// object and TOML otherwise, into profiles of key values
+func parseConfig(data []byte, allowProfiles bool) (map[string]map[string]string, error) {
+	var profiles map[string]map[string]string
+	var err error
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		profiles, err = parseConfigJSON(trimmed)
+	} else {
+		profiles, err = parseConfigTOML(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range sortedProfileNames(profiles) {
+		if name != DefaultProfile && !allowProfiles {
+			return nil, fmt.Errorf("profiles not allowed, found '%s'", name)
+		}
+		for key := range profiles[name] {
+			if !configKeys[key] {
+				return nil, fmt.Errorf("unknown key '%s' in profile '%s'", key, name)
+			}
+		}
+	}
+	return profiles, nil
+}
+
+func parseConfigJSON(data []byte) (map[string]map[string]string, error) {
+	var raw map[string]json.RawMessage
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, err
+	}
+	rv := map[string]map[string]string{}
+	for key, value := range raw {
+		var s string
+		if json.Unmarshal(value, &s) == nil {
+			setConfigValue(rv, DefaultProfile, key, s)
+			continue
+		}
+		var profile map[string]string
+		err = json.Unmarshal(value, &profile)
+		if err != nil {
+			return nil, fmt.Errorf("profile '%s' must be an object of strings", key)
+		}
+		for k, v := range profile {
+			setConfigValue(rv, key, k, v)
+		}
+	}
+	return rv, nil
+}
+
+// parseConfigTOML parses the subset of TOML used by config files, tables
+// of string values
+func parseConfigTOML(data []byte) (map[string]map[string]string, error) {
+	rv := map[string]map[string]string{}
+	profile := DefaultProfile
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if line[0] == '[' {
+			end := strings.IndexByte(line, ']')
+			if end < 0 || !isTOMLComment(line[end+1:]) {
+				return nil, fmt.Errorf("line %d: invalid table", i+1)
+			}
+			name, err := tomlKey(line[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", i+1, err)
+			}
+			if _, ok := rv[name]; ok && name != DefaultProfile {
+				return nil, fmt.Errorf("line %d: duplicate profile '%s'", i+1, name)
+			}
+			profile = name
+			if rv[profile] == nil {
+				rv[profile] = map[string]string{}
+			}
+			continue
+		}
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", i+1)
+		}
+		key, err := tomlKey(line[:eq])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		value, err := tomlString(strings.TrimSpace(line[eq+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		setConfigValue(rv, profile, key, value)
+	}
+	return rv, nil
+}
+
+// tomlKey parses a bare or quoted key
+func tomlKey(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, `"`) || strings.HasPrefix(s, "'") {
+		return tomlString(s)
+	}
+	if s == "" {
+		return "", fmt.Errorf("empty key")
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return "", fmt.Errorf("invalid key '%s'", s)
+		}
+	}
+	return s, nil
+}
+
+// tomlString parses a basic or literal string, followed by an optional
+// comment
+func tomlString(s string) (string, error) {
+	if strings.HasPrefix(s, "'") {
+		end := strings.IndexByte(s[1:], '\'')
+		if end >= 0 && isTOMLComment(s[end+2:]) {
+			return s[1 : end+1], nil
+		}
+	} else if strings.HasPrefix(s, `"`) {
+		for end := 1; end < len(s); end++ {
+			if s[end] == '\\' {
+				end++
+				continue
+			}
+			if s[end] == '"' {
+				if !isTOMLComment(s[end+1:]) {
+					break
+				}
+				return strconv.Unquote(s[:end+1])
+			}
+		}
+	}
+	return "", fmt.Errorf("expected a quoted string, got %s", s)
+}
+
+func isTOMLComment(s string) bool {
+	s = strings.TrimSpace(s)
+	return s == "" || s[0] == '#'
+}
+
+func setConfigValue(profiles map[string]map[string]string, profile, key, value string) {
+	if profiles[profile] == nil {
+		profiles[profile] = map[string]string{}
+	}
+	profiles[profile][key] = value
+}
+
+func sortedProfileNames(profiles map[string]map[string]string) []string {
+	rv := make([]string, 0, len(profiles))
+	for name := range profiles {
+		rv = append(rv, name)
+	}
+	sort.Strings(rv)
+	return rv
+}
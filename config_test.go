@@ -0,0 +1,232 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package luno
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testConfigTOML = `
+# the default profile
+api_key = "default-key"
+secret_key = "default-secret" # trailing comment
+
+[staging]
+api_key = 'staging-key'
+secret_key = "staging-\"secret\""
+base_url = "https://staging.example.com/luno"
+
+[prod]
+secrets_file = "prod.secrets"
+`
+
+func writeConfigFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	err := os.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func testGetenv(env map[string]string) func(string) string {
+	return func(name string) string {
+		return env[name]
+	}
+}
+
+func TestConfigLoader(t *testing.T) {
+	dir := t.TempDir()
+	configPath := writeConfigFile(t, dir, "config", testConfigTOML)
+	writeConfigFile(t, dir, "prod.secrets", `{"api_key": "prod-key", "secret_key": "prod-secret"}`)
+	secretOnly := writeConfigFile(t, dir, "secret-only.secrets", "secret_key = \"only-secret\"\n")
+	otherSecrets := writeConfigFile(t, dir, "other.secrets", "api_key = \"other-key\"\nsecret_key = \"other-secret\"\nbase_url = \"https://other.example.com\"\n")
+
+	tests := []struct {
+		name   string
+		loader ConfigLoader
+		env    map[string]string
+		want   Config
+	}{
+		{
+			name:   "default profile",
+			loader: ConfigLoader{Path: configPath},
+			want:   Config{APIKey: "default-key", SecretKey: "default-secret", Profile: "default"},
+		},
+		{
+			name:   "named profile",
+			loader: ConfigLoader{Path: configPath, Profile: "staging"},
+			want:   Config{APIKey: "staging-key", SecretKey: `staging-"secret"`, BaseURL: "https://staging.example.com/luno", Profile: "staging"},
+		},
+		{
+			name: "profile and config from env",
+			env:  map[string]string{EnvConfigFile: configPath, EnvProfile: "staging"},
+			want: Config{APIKey: "staging-key", SecretKey: `staging-"secret"`, BaseURL: "https://staging.example.com/luno", Profile: "staging"},
+		},
+		{
+			name:   "profile secrets file",
+			loader: ConfigLoader{Path: configPath, Profile: "prod"},
+			want:   Config{APIKey: "prod-key", SecretKey: "prod-secret", Profile: "prod"},
+		},
+		{
+			name:   "secrets file overrides profile",
+			loader: ConfigLoader{Path: configPath, Profile: "staging"},
+			env:    map[string]string{EnvSecretsFile: otherSecrets},
+			want:   Config{APIKey: "other-key", SecretKey: "other-secret", BaseURL: "https://other.example.com", Profile: "staging"},
+		},
+		{
+			name:   "secrets file with only the secret key",
+			loader: ConfigLoader{Path: configPath, Profile: "staging", SecretsFile: secretOnly},
+			want:   Config{APIKey: "staging-key", SecretKey: "only-secret", BaseURL: "https://staging.example.com/luno", Profile: "staging"},
+		},
+		{
+			name:   "env overrides all",
+			loader: ConfigLoader{Path: configPath, Profile: "staging", SecretsFile: otherSecrets},
+			env:    map[string]string{EnvAPIKey: "env-key", EnvSecretKey: "env-secret", EnvBaseURL: "http://localhost:8080"},
+			want:   Config{APIKey: "env-key", SecretKey: "env-secret", BaseURL: "http://localhost:8080", Profile: "staging"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.loader.Getenv = testGetenv(test.env)
+			got, err := test.loader.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *got != test.want {
+				t.Errorf("expected %+v, got %+v", test.want, *got)
+			}
+		})
+	}
+}
+
+func TestConfigLoaderEnvOnly(t *testing.T) {
+	// a missing default config file is not an error
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	loader := ConfigLoader{Getenv: testGetenv(map[string]string{EnvAPIKey: "env-key", EnvSecretKey: "env-secret"})}
+	config, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Config{APIKey: "env-key", SecretKey: "env-secret", Profile: "default"}
+	if *config != want {
+		t.Errorf("expected %+v, got %+v", want, *config)
+	}
+}
+
+func TestConfigLoaderJSON(t *testing.T) {
+	dir := t.TempDir()
+	configPath := writeConfigFile(t, dir, "config.json", `{
+		"api_key": "default-key",
+		"secret_key": "default-secret",
+		"prod": {"api_key": "prod-key", "secret_key": "prod-secret", "base_url": "https://prod.example.com"}
+	}`)
+	loader := ConfigLoader{Path: configPath, Profile: "prod", Getenv: testGetenv(nil)}
+	config, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Config{APIKey: "prod-key", SecretKey: "prod-secret", BaseURL: "https://prod.example.com", Profile: "prod"}
+	if *config != want {
+		t.Errorf("expected %+v, got %+v", want, *config)
+	}
+
+	lunoClient := config.NewClient()
+	if lunoClient.host != "prod.example.com" || lunoClient.signer.Key != "prod-key" {
+		t.Errorf("client not configured from config, host %s key %s", lunoClient.host, lunoClient.signer.Key)
+	}
+}
+
+func TestConfigLoaderErrors(t *testing.T) {
+	dir := t.TempDir()
+	configPath := writeConfigFile(t, dir, "config", testConfigTOML)
+
+	tests := []struct {
+		name    string
+		config  string
+		loader  ConfigLoader
+		env     map[string]string
+		wantErr string
+	}{
+		{
+			name:    "missing profile",
+			loader:  ConfigLoader{Path: configPath, Profile: "qa"},
+			wantErr: "profile 'qa' not found",
+		},
+		{
+			name:    "missing explicit file",
+			loader:  ConfigLoader{Path: filepath.Join(dir, "missing")},
+			wantErr: "error reading luno config",
+		},
+		{
+			name:    "missing secrets file",
+			loader:  ConfigLoader{Path: configPath, SecretsFile: filepath.Join(dir, "missing")},
+			wantErr: "error reading luno secrets file",
+		},
+		{
+			name:    "half env credentials",
+			loader:  ConfigLoader{Path: configPath},
+			env:     map[string]string{EnvAPIKey: "env-key"},
+			wantErr: "must be set together",
+		},
+		{
+			name:    "no credentials",
+			config:  "[staging]\nbase_url = \"https://staging.example.com\"\n",
+			loader:  ConfigLoader{Profile: "staging"},
+			wantErr: "api_key and secret_key required",
+		},
+		{
+			name:    "invalid base url",
+			loader:  ConfigLoader{Path: configPath},
+			env:     map[string]string{EnvBaseURL: "localhost"},
+			wantErr: "invalid base url",
+		},
+		{
+			name:    "unknown key",
+			config:  "api_key = \"k\"\nsecret = \"s\"\n",
+			wantErr: "unknown key 'secret'",
+		},
+		{
+			name:    "unquoted value",
+			config:  "api_key = k\n",
+			wantErr: "line 1: expected a quoted string",
+		},
+		{
+			name:    "duplicate profile",
+			config:  "[a]\n[a]\n",
+			wantErr: "line 2: duplicate profile 'a'",
+		},
+		{
+			name:    "invalid json",
+			config:  `{"api_key": 1}`,
+			wantErr: "must be an object of strings",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.config != "" {
+				test.loader.Path = writeConfigFile(t, t.TempDir(), "config", test.config)
+			}
+			test.loader.Getenv = testGetenv(test.env)
+			_, err := test.loader.Load()
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("expected error containing %q, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
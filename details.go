@@ -0,0 +1,122 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package luno
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// DecodeDetails decodes an untyped payload, such as User.Profile or
+// Session.Details, into v which must be a pointer, typically to a struct
+// with json tags.  A nil payload leaves v unchanged.
+func DecodeDetails(details interface{}, v interface{}) error {
+	if details == nil {
+		return nil
+	}
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		return fmt.Errorf("error marshaling luno details json: %v", err)
+	}
+	err = json.Unmarshal(detailsJSON, v)
+	if err != nil {
+		return fmt.Errorf("error parsing luno details json: '%s' err: %v", detailsJSON, err)
+	}
+	return nil
+}
+
+// DetailsAs decodes an untyped payload, such as User.Profile or
+// Session.Details, into a new value of type T
+func DetailsAs[T any](details interface{}) (T, error) {
+	if rv, ok := details.(T); ok {
+		return rv, nil
+	}
+	var rv T
+	err := DecodeDetails(details, &rv)
+	return rv, err
+}
+
+// EncodeDetails converts v, typically a struct with json tags, into the
+// untyped map form used for User.Profile and the Details of other entities.
+// Using the map form ensures that a partial update merges keys as expected.
+func EncodeDetails(v interface{}) (map[string]interface{}, error) {
+	detailsJSON, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling luno details json: %v", err)
+	}
+	var rv map[string]interface{}
+	err = json.Unmarshal(detailsJSON, &rv)
+	if err != nil {
+		return nil, fmt.Errorf("luno details must be a json object, got: '%s'", detailsJSON)
+	}
+	return rv, nil
+}
+
+// DecodeProfile decodes the User Profile into v, see DecodeDetails
+func (u *User) DecodeProfile(v interface{}) error {
+	return DecodeDetails(u.Profile, v)
+}
+
+// SetProfile sets the User Profile from v, see EncodeDetails
+func (u *User) SetProfile(v interface{}) error {
+	profile, err := EncodeDetails(v)
+	if err != nil {
+		return err
+	}
+	u.Profile = profile
+	return nil
+}
+
+// DecodeDetails decodes the Session Details into v, see DecodeDetails
+func (s *Session) DecodeDetails(v interface{}) error {
+	return DecodeDetails(s.Details, v)
+}
+
+// SetDetails sets the Session Details from v, see EncodeDetails
+func (s *Session) SetDetails(v interface{}) error {
+	details, err := EncodeDetails(v)
+	if err != nil {
+		return err
+	}
+	s.Details = details
+	return nil
+}
+
+// DecodeDetails decodes the Event Details into v, see DecodeDetails
+func (e *Event) DecodeDetails(v interface{}) error {
+	return DecodeDetails(e.Details, v)
+}
+
+// SetDetails sets the Event Details from v, see EncodeDetails
+func (e *Event) SetDetails(v interface{}) error {
+	details, err := EncodeDetails(v)
+	if err != nil {
+		return err
+	}
+	e.Details = details
+	return nil
+}
+
+// DecodeDetails decodes the APIAuth Details into v, see DecodeDetails
+func (a *APIAuth) DecodeDetails(v interface{}) error {
+	return DecodeDetails(a.Details, v)
+}
+
+// SetDetails sets the APIAuth Details from v, see EncodeDetails
+func (a *APIAuth) SetDetails(v interface{}) error {
+	details, err := EncodeDetails(v)
+	if err != nil {
+		return err
+	}
+	a.Details = details
+	return nil
+}
@@ -0,0 +1,107 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package luno_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mschoch/luno-go"
+)
+
+type clownProfile struct {
+	Title      string   `json:"title,omitempty"`
+	Instrument string   `json:"instrument,omitempty"`
+	Shoes      int      `json:"shoes,omitempty"`
+	Tricks     []string `json:"tricks,omitempty"`
+}
+
+func TestTypedDetails(t *testing.T) {
+	lunoClient := newTestClient(t)
+
+	newUser := &luno.User{
+		Name:     "Bozo Clown",
+		Email:    "bozo@clown.com",
+		Password: "h8clownz",
+	}
+	err := newUser.SetProfile(&clownProfile{Title: "clown king", Shoes: 2, Tricks: []string{"juggle"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	createdUser, err := lunoClient.Users.Create(newUser, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// extend the profile using a partial typed update
+	err = createdUser.SetProfile(&clownProfile{Instrument: "trumpet"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = lunoClient.Users.Update(createdUser, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := lunoClient.Users.Get(createdUser.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile, err := luno.DetailsAs[clownProfile](user.Profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := clownProfile{Title: "clown king", Instrument: "trumpet", Shoes: 2, Tricks: []string{"juggle"}}
+	if !reflect.DeepEqual(expected, profile) {
+		t.Errorf("expected profile %v, got %v", expected, profile)
+	}
+
+	var decoded clownProfile
+	err = user.DecodeProfile(&decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, decoded) {
+		t.Errorf("expected decoded profile %v, got %v", expected, decoded)
+	}
+
+	err = lunoClient.Users.Delete(createdUser.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDetailsErrors(t *testing.T) {
+	_, err := luno.DetailsAs[clownProfile](map[string]interface{}{"shoes": "big"})
+	if err == nil {
+		t.Errorf("expected error decoding mismatched type")
+	}
+
+	_, err = luno.EncodeDetails([]string{"not", "an", "object"})
+	if err == nil {
+		t.Errorf("expected error encoding non-object details")
+	}
+
+	session := &luno.Session{}
+	var v clownProfile
+	err = session.DecodeDetails(&v)
+	if err != nil || !reflect.DeepEqual(clownProfile{}, v) {
+		t.Errorf("expected nil details to decode to zero value, got %v %v", v, err)
+	}
+
+	// values already of the requested type are returned directly
+	details := map[string]interface{}{"a": "b"}
+	m, err := luno.DetailsAs[map[string]interface{}](details)
+	if err != nil || m["a"] != "b" {
+		t.Errorf("expected map details returned directly, got %v %v", m, err)
+	}
+}
@@ -29,6 +29,22 @@ type Page struct {
 	Prev Entity `json:"prev"`
 }
 
+// NextPaging returns the Paging to request the next page, with the provided
+// limit, or nil if this is the last page
+func (p *Page) NextPaging(limit int) *Paging {
+	if p.Next.URL != "" {
+		if u, err := url.Parse(p.Next.URL); err == nil {
+			if from := u.Query().Get("from"); from != "" {
+				return &Paging{From: from, Limit: limit}
+			}
+		}
+	}
+	if p.Next.ID != "" {
+		return &Paging{From: p.Next.ID, Limit: limit}
+	}
+	return nil
+}
+
 // Paging contains request options related to paging
 type Paging struct {
 	From  string `json:"from"`
@@ -12,10 +12,16 @@
 package luno
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 // ErrNotImplemented is returned for any API method not yet implemented
@@ -23,9 +29,59 @@ var ErrNotImplemented = fmt.Errorf("not implemented")
 
 // Error Codes used by Luno - https://luno.io/docs/errors
 const (
+	// request errors
+	ErrCodeInvalidParams    = "invalid_params"
+	ErrCodeNotFound         = "not_found"
+	ErrCodeMethodNotAllowed = "method_not_allowed"
+
+	// authentication of API requests
+	ErrCodeInvalidAPIKey    = "invalid_api_key"
+	ErrCodeInvalidTimestamp = "invalid_timestamp"
+	ErrCodeInvalidSignature = "invalid_signature"
+
+	// users
+	ErrCodeUserNotFound      = "user_not_found"
+	ErrCodeEmailTaken        = "email_taken"
+	ErrCodeUsernameTaken     = "username_taken"
 	ErrCodeIncorrectPassword = "incorrect_password"
 	ErrCodeUserClosed        = "user_closed"
-	ErrCodeSessionNotFound   = "session_not_found"
+
+	// sessions
+	ErrCodeSessionNotFound = "session_not_found"
+	ErrCodeSessionExpired  = "session_expired"
+
+	// events
+	ErrCodeEventNotFound = "event_not_found"
+
+	// api authentication
+	ErrCodeAPIAuthNotFound = "api_authentication_not_found"
+
+	// service errors
+	ErrCodeRateLimited        = "rate_limited"
+	ErrCodeInternal           = "internal_error"
+	ErrCodeServiceUnavailable = "service_unavailable"
+)
+
+// Sentinel errors for use with errors.Is, any *Error with the same Code
+// matches, regardless of the other fields
+var (
+	ErrInvalidParams      = &Error{Code: ErrCodeInvalidParams}
+	ErrNotFound           = &Error{Code: ErrCodeNotFound}
+	ErrInvalidAPIKey      = &Error{Code: ErrCodeInvalidAPIKey}
+	ErrInvalidTimestamp   = &Error{Code: ErrCodeInvalidTimestamp}
+	ErrInvalidSignature   = &Error{Code: ErrCodeInvalidSignature}
+	ErrUserNotFound       = &Error{Code: ErrCodeUserNotFound}
+	ErrEmailTaken         = &Error{Code: ErrCodeEmailTaken}
+	ErrUsernameTaken      = &Error{Code: ErrCodeUsernameTaken}
+	ErrIncorrectPassword  = &Error{Code: ErrCodeIncorrectPassword}
+	ErrUserClosed         = &Error{Code: ErrCodeUserClosed}
+	ErrSessionNotFound    = &Error{Code: ErrCodeSessionNotFound}
+	ErrSessionExpired     = &Error{Code: ErrCodeSessionExpired}
+	ErrEventNotFound      = &Error{Code: ErrCodeEventNotFound}
+	ErrAPIAuthNotFound    = &Error{Code: ErrCodeAPIAuthNotFound}
+	ErrRateLimited        = &Error{Code: ErrCodeRateLimited}
+	ErrInternal           = &Error{Code: ErrCodeInternal}
+	ErrServiceUnavailable = &Error{Code: ErrCodeServiceUnavailable}
 )
 
 // Error represents all the information in a Luno Error
@@ -42,26 +98,235 @@ func (l *Error) Error() string {
 		l.Code, l.Message, l.Description, l.Status, l.Extra)
 }
 
-// ParseError parses a Luno error from an HTTP response
+// Is reports whether target is a Luno *Error with the same Code, this allows
+// the sentinel errors to be used with errors.Is
+func (l *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == l.Code
+}
+
+// UserNotFoundError is returned when no user has the email or username
+// being looked up, it matches ErrUserNotFound with errors.Is
+type UserNotFoundError struct {
+	// Field is "email" or "username"
+	Field string
+	Value string
+	Err   error
+}
+
+func (e *UserNotFoundError) Error() string {
+	return fmt.Sprintf("luno user with %s '%s' not found", e.Field, e.Value)
+}
+
+// Unwrap returns the error returned by Luno
+func (e *UserNotFoundError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrUserNotFound
+func (e *UserNotFoundError) Is(target error) bool {
+	return ErrUserNotFound.Is(target)
+}
+
+// maxBodySnippet is the maximum number of bytes of a response body kept in
+// a RequestError
+const maxBodySnippet = 512
+
+// RequestError describes a failed request to the Luno API, it wraps the
+// underlying cause, which is a *Error when Luno responded with an error, or
+// the transport error if no response was received
+type RequestError struct {
+	Op         string        // logical operation, such as "users.get"
+	Method     string        // HTTP method
+	URL        string        // request URL, with sensitive parameters redacted
+	StatusCode int           // HTTP status code, 0 if no response was received
+	Attempt    int           // attempt number, starting at 1
+	Latency    time.Duration // time from sending the request to the failure
+	Body       string        // start of the response body, if any
+	Err        error
+}
+
+func (e *RequestError) Error() string {
+	msg := "luno request"
+	if e.Op != "" {
+		msg += " " + e.Op
+	}
+	msg += fmt.Sprintf(" %s %s attempt %d", e.Method, e.URL, e.Attempt)
+	if e.StatusCode != 0 {
+		msg += fmt.Sprintf(" status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("%s after %v: %v", msg, e.Latency, e.Err)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// newRequestError builds a RequestError for req, the operation, attempt and
+// latency are taken from the request context when it was made by the Client.
+// The URL in err is redacted, see redactError.
+func newRequestError(req *http.Request, err error) *RequestError {
+	rv := &RequestError{Err: redactError(err)}
+	if req == nil {
+		return rv
+	}
+	rv.Method = req.Method
+	if req.URL != nil {
+		if req.URL.Opaque != "" {
+			rv.URL = redactURL(req.URL.Opaque)
+		} else {
+			rv.URL = redactURL(req.URL.RequestURI())
+		}
+	}
+	if info, ok := requestInfoFromContext(req.Context()); ok {
+		rv.Op = info.operation
+		rv.Attempt = info.attempt
+		if !info.start.IsZero() {
+			rv.Latency = time.Since(info.start)
+		}
+	}
+	return rv
+}
+
+// bodySnippet returns the start of a response body, JSON bodies have
+// sensitive values redacted, others are kept as is
+func bodySnippet(body []byte) string {
+	rv := string(body)
+	if json.Valid(body) {
+		rv = redactJSON(body)
+	}
+	if len(rv) > maxBodySnippet {
+		cut := maxBodySnippet
+		for cut > 0 && !utf8.RuneStart(rv[cut]) {
+			cut--
+		}
+		rv = rv[:cut] + "..."
+	}
+	return rv
+}
+
+// ParseError parses a Luno error from an HTTP response, returning an
+// *Error, or an error describing why the body could not be parsed.  Errors
+// returned by the Client wrap the *Error in a *RequestError, so callers
+// should use errors.As, or IsErrorCode, rather than a type assertion.
 func ParseError(resp *http.Response) error {
 	respBytes, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return fmt.Errorf("error reading luno error body: %v", err)
 	}
+	return parseErrorBody(resp.StatusCode, respBytes)
+}
+
+// responseError builds a RequestError for an error response, wrapping the
+// error parsed from its body
+func responseError(resp *http.Response) *RequestError {
+	rerr := newRequestError(resp.Request, nil)
+	rerr.StatusCode = resp.StatusCode
+	respBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		rerr.Err = fmt.Errorf("error reading luno error body: %v", err)
+		return rerr
+	}
+	rerr.Body = bodySnippet(respBytes)
+	rerr.Err = parseErrorBody(resp.StatusCode, respBytes)
+	return rerr
+}
+
+func parseErrorBody(status int, body []byte) error {
 	var rv Error
-	err = json.Unmarshal(respBytes, &rv)
+	err := json.Unmarshal(body, &rv)
 	if err != nil {
-		return fmt.Errorf("error parsing luno error json: '%s' err: %v", respBytes, err)
+		return fmt.Errorf("error parsing luno error json: %v", err)
+	}
+	if rv.Status == 0 {
+		rv.Status = status
 	}
 	return &rv
 }
 
-// IsErrorCode checks is an error was a luno error with the matching code
+// IsErrorCode checks is an error was a luno error with the matching code,
+// the luno error may be wrapped
 func IsErrorCode(err error, code string) bool {
-	if err, ok := err.(*Error); ok {
-		if err.Code == code {
+	var lerr *Error
+	if errors.As(err, &lerr) {
+		return lerr.Code == code
+	}
+	return false
+}
+
+// IsNotFound checks if an error was a luno error indicating the requested
+// entity does not exist
+func IsNotFound(err error) bool {
+	var lerr *Error
+	if errors.As(err, &lerr) {
+		return lerr.Status == http.StatusNotFound ||
+			lerr.Code == ErrCodeNotFound ||
+			strings.HasSuffix(lerr.Code, "_"+ErrCodeNotFound)
+	}
+	return false
+}
+
+// IsConflict checks if an error was a luno error indicating a conflict with
+// an existing entity, such as an email address already being taken
+func IsConflict(err error) bool {
+	var lerr *Error
+	if errors.As(err, &lerr) {
+		return lerr.Status == http.StatusConflict ||
+			lerr.Code == ErrCodeEmailTaken ||
+			lerr.Code == ErrCodeUsernameTaken
+	}
+	return false
+}
+
+// IsAuth checks if an error was a luno error indicating an authentication
+// failure, either of the API request itself or of a user
+func IsAuth(err error) bool {
+	var lerr *Error
+	if errors.As(err, &lerr) {
+		switch lerr.Code {
+		case ErrCodeInvalidAPIKey, ErrCodeInvalidTimestamp, ErrCodeInvalidSignature,
+			ErrCodeIncorrectPassword, ErrCodeUserClosed, ErrCodeSessionExpired:
 			return true
 		}
+		return lerr.Status == http.StatusUnauthorized || lerr.Status == http.StatusForbidden
 	}
 	return false
 }
+
+// IsRetryable checks if an error is likely to be temporary, such that
+// retrying the same request later may succeed.  This includes throttling,
+// Luno service errors and network errors, but not cancellation or deadlines
+// of the context.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var rateLimitErr *RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		return true
+	}
+	var lerr *Error
+	if errors.As(err, &lerr) {
+		switch lerr.Code {
+		case ErrCodeRateLimited, ErrCodeInternal, ErrCodeServiceUnavailable:
+			return true
+		}
+		switch lerr.Status {
+		case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	var reqErr *RequestError
+	if errors.As(err, &reqErr) && reqErr.StatusCode != 0 {
+		switch reqErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,189 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package luno
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestErrorsIsAs(t *testing.T) {
+	lerr := &Error{Code: ErrCodeUserNotFound, Message: "User not found", Status: http.StatusNotFound}
+	wrapped := fmt.Errorf("looking up user: %w", lerr)
+
+	if !errors.Is(wrapped, ErrUserNotFound) {
+		t.Errorf("expected wrapped error to match ErrUserNotFound")
+	}
+	if errors.Is(wrapped, ErrSessionNotFound) {
+		t.Errorf("expected wrapped error not to match ErrSessionNotFound")
+	}
+	if !IsErrorCode(wrapped, ErrCodeUserNotFound) {
+		t.Errorf("expected IsErrorCode to see through wrapping")
+	}
+	var asErr *Error
+	if !errors.As(wrapped, &asErr) || asErr != lerr {
+		t.Errorf("expected errors.As to find the luno error")
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+var _ net.Error = timeoutError{}
+
+func TestErrorClassification(t *testing.T) {
+	tests := []struct {
+		err       error
+		notFound  bool
+		conflict  bool
+		auth      bool
+		retryable bool
+	}{
+		{err: nil},
+		{err: fmt.Errorf("something else")},
+		{err: &Error{Code: ErrCodeSessionNotFound, Status: 404}, notFound: true},
+		{err: &Error{Code: ErrCodeAPIAuthNotFound}, notFound: true},
+		{err: &Error{Code: ErrCodeEmailTaken, Status: 409}, conflict: true},
+		{err: &Error{Code: "something_duplicated", Status: 409}, conflict: true},
+		{err: &Error{Code: ErrCodeIncorrectPassword, Status: 401}, auth: true},
+		{err: &Error{Code: ErrCodeInvalidSignature}, auth: true},
+		{err: &Error{Code: "forbidden", Status: 403}, auth: true},
+		{err: &Error{Code: ErrCodeInternal, Status: 500}, retryable: true},
+		{err: &Error{Code: "unavailable", Status: 503}, retryable: true},
+		{err: &RateLimitError{Err: &Error{Code: ErrCodeRateLimited, Status: 429}}, retryable: true},
+		{err: fmt.Errorf("wrapped: %w", timeoutError{}), retryable: true},
+		{err: context.Canceled},
+		{err: fmt.Errorf("wrapped: %w", context.DeadlineExceeded)},
+		{err: &Error{Code: ErrCodeInvalidParams, Status: 400}},
+	}
+	for _, test := range tests {
+		if got := IsNotFound(test.err); got != test.notFound {
+			t.Errorf("IsNotFound(%v) expected %t", test.err, test.notFound)
+		}
+		if got := IsConflict(test.err); got != test.conflict {
+			t.Errorf("IsConflict(%v) expected %t", test.err, test.conflict)
+		}
+		if got := IsAuth(test.err); got != test.auth {
+			t.Errorf("IsAuth(%v) expected %t", test.err, test.auth)
+		}
+		if got := IsRetryable(test.err); got != test.retryable {
+			t.Errorf("IsRetryable(%v) expected %t", test.err, test.retryable)
+		}
+	}
+}
+
+func TestRequestErrorFromResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"code":"user_not_found","message":"User not found"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("key", "secret", WithBaseURL(server.URL))
+	_, err := client.Users.Get("usr_missing")
+	var reqErr *RequestError
+	if !errors.As(err, &reqErr) {
+		t.Fatalf("expected *RequestError, got %T: %v", err, err)
+	}
+	if reqErr.Op != "users.get" || reqErr.Method != http.MethodGet || reqErr.Attempt != 1 {
+		t.Errorf("unexpected request details %+v", reqErr)
+	}
+	if reqErr.StatusCode != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", reqErr.StatusCode)
+	}
+	if !strings.Contains(reqErr.URL, "/v1/users/usr_missing") || strings.Contains(reqErr.URL, "key=key") {
+		t.Errorf("expected redacted url, got %s", reqErr.URL)
+	}
+	if !strings.Contains(reqErr.Body, "user_not_found") {
+		t.Errorf("expected body snippet, got %s", reqErr.Body)
+	}
+	if !errors.Is(err, ErrUserNotFound) || !IsNotFound(err) {
+		t.Errorf("expected wrapped luno error to match, got %v", err)
+	}
+}
+
+func TestRequestErrorNonJSONBody(t *testing.T) {
+	page := "<html>" + strings.Repeat("x", 2*maxBodySnippet) + "</html>"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = w.Write([]byte(page))
+	}))
+	defer server.Close()
+
+	client := NewClient("key", "secret", WithBaseURL(server.URL), WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+	_, err := client.Users.Get("usr_xxx")
+	var reqErr *RequestError
+	if !errors.As(err, &reqErr) {
+		t.Fatalf("expected *RequestError, got %T: %v", err, err)
+	}
+	if reqErr.StatusCode != http.StatusBadGateway {
+		t.Errorf("expected status 502, got %d", reqErr.StatusCode)
+	}
+	if !strings.HasPrefix(reqErr.Body, "<html>") || len(reqErr.Body) > maxBodySnippet+3 {
+		t.Errorf("expected truncated raw body, got %q", reqErr.Body)
+	}
+	if !IsRetryable(err) {
+		t.Errorf("expected 502 to be retryable")
+	}
+}
+
+func TestRequestErrorTransportFailure(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	client := NewClient("myapikey", "secret", WithBaseURL(server.URL), WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+	_, err := client.Sessions.Get("ses_xxx")
+	var reqErr *RequestError
+	if !errors.As(err, &reqErr) {
+		t.Fatalf("expected *RequestError, got %T: %v", err, err)
+	}
+	if reqErr.Op != "sessions.get" || reqErr.StatusCode != 0 || reqErr.Err == nil {
+		t.Errorf("unexpected request error %+v", reqErr)
+	}
+	if !IsRetryable(err) {
+		t.Errorf("expected transport failure to be retryable")
+	}
+	msg := err.Error()
+	if strings.Contains(msg, "myapikey") || strings.Count(msg, "sign=") != strings.Count(msg, "sign="+redacted) {
+		t.Errorf("expected key and signature to be redacted, got %s", msg)
+	}
+	if !strings.Contains(msg, "/v1/sessions/ses_xxx") {
+		t.Errorf("expected error to name the request, got %s", msg)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	resp := &http.Response{
+		StatusCode: http.StatusNotFound,
+		Body:       io.NopCloser(strings.NewReader(`{"code":"user_not_found","message":"User not found"}`)),
+	}
+	lerr, ok := ParseError(resp).(*Error)
+	if !ok || lerr.Code != ErrCodeUserNotFound || lerr.Status != http.StatusNotFound {
+		t.Errorf("expected *Error, got %#v", lerr)
+	}
+
+	resp.Body = io.NopCloser(strings.NewReader("<html>"))
+	err := ParseError(resp)
+	if _, ok := err.(*Error); ok || !strings.Contains(err.Error(), "error parsing luno error json") {
+		t.Errorf("expected parse error, got %v", err)
+	}
+}
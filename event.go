@@ -49,7 +49,7 @@ type EventFilter struct {
 type Event struct {
 	Entity
 	UserID    string      `json:"user_id,omitempty"`
-	Timestamp string      `json:"timestamp,omitempty"`
+	Timestamp Time        `json:"timestamp,omitzero"`
 	Name      string      `json:"name,omitempty"`
 	Details   interface{} `json:"details,omitempty"`
 	User      *User       `json:"user,omitempty"`
@@ -0,0 +1,268 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package luno
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrPublisherQueueFull is reported for events dropped because the queue of
+// an EventPublisher is full
+var ErrPublisherQueueFull = errors.New("luno event publisher queue is full")
+
+// ErrPublisherClosed is reported for events published after an
+// EventPublisher was closed
+var ErrPublisherClosed = errors.New("luno event publisher is closed")
+
+// EventPublisherConfig controls the queue, workers and retries of an
+// EventPublisher
+type EventPublisherConfig struct {
+	// QueueSize is the maximum number of events waiting to be sent, when
+	// the queue is full new events are dropped
+	QueueSize int
+	// Workers is the number of events sent concurrently
+	Workers int
+	// Timeout limits each attempt to send an event, zero means no limit
+	// other than that of the client
+	Timeout time.Duration
+	// RetryPolicy controls the retries of events which fail to send with a
+	// retryable error, the methods of the policy are ignored, as events
+	// are always retried.  Note a retried event may be recorded twice.
+	RetryPolicy RetryPolicy
+	// OnError, if set, is called with events which were dropped or failed
+	// to send, it is called from the worker goroutines and from Publish
+	OnError func(event *Event, err error)
+}
+
+// DefaultEventPublisherConfig is the EventPublisherConfig suitable for most
+// applications
+var DefaultEventPublisherConfig = EventPublisherConfig{
+	QueueSize: 1000,
+	Workers:   4,
+	Timeout:   10 * time.Second,
+	RetryPolicy: RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   100 * time.Millisecond,
+		MaxDelay:    2 * time.Second,
+		Jitter:      0.5,
+	},
+}
+
+// EventPublisherStats describes the state of an EventPublisher
+type EventPublisherStats struct {
+	Queued    int    // events waiting to be sent
+	InFlight  int    // events being sent
+	Published uint64 // events sent successfully
+	Failed    uint64 // events which failed to send
+	Dropped   uint64 // events dropped without being sent
+}
+
+// EventPublisher sends events to Luno asynchronously, from a bounded queue
+// served by a pool of workers.  It is safe for concurrent use.
+type EventPublisher struct {
+	client *Client
+	config EventPublisherConfig
+	queue  chan *Event
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	m       sync.Mutex
+	closed  bool
+	pending int
+	idle    chan struct{}
+
+	inFlight  atomic.Int64
+	published atomic.Uint64
+	failed    atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+// NewEventPublisher builds a new EventPublisher sending events with the
+// provided client, and starts its workers.  Callers should Close the
+// publisher to send queued events before exiting.
+func NewEventPublisher(client *Client, config EventPublisherConfig) *EventPublisher {
+	if config.QueueSize < 1 {
+		config.QueueSize = 1
+	}
+	if config.Workers < 1 {
+		config.Workers = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	rv := &EventPublisher{
+		client: client,
+		config: config,
+		queue:  make(chan *Event, config.QueueSize),
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		idle:   make(chan struct{}),
+	}
+	close(rv.idle)
+
+	var wg sync.WaitGroup
+	wg.Add(config.Workers)
+	for i := 0; i < config.Workers; i++ {
+		go func() {
+			defer wg.Done()
+			rv.work()
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(rv.done)
+	}()
+	return rv
+}
+
+// Publish queues an event to be sent, without waiting.  If the event cannot
+// be queued, because the queue is full or the publisher is closed, it is
+// reported to OnError and the error is returned.
+func (p *EventPublisher) Publish(event *Event) error {
+	p.m.Lock()
+	var err error
+	if p.closed {
+		err = ErrPublisherClosed
+	} else {
+		select {
+		case p.queue <- event:
+			if p.pending == 0 {
+				p.idle = make(chan struct{})
+			}
+			p.pending++
+		default:
+			err = ErrPublisherQueueFull
+		}
+	}
+	p.m.Unlock()
+
+	if err != nil {
+		p.drop(event, err)
+	}
+	return err
+}
+
+// Flush waits until all queued events have been sent, or have failed, or
+// the context is done
+func (p *EventPublisher) Flush(ctx context.Context) error {
+	p.m.Lock()
+	idle := p.idle
+	p.m.Unlock()
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting events and waits for queued events to be sent.  If
+// the context is done first, sending is abandoned, the remaining events are
+// dropped and the context error is returned.
+func (p *EventPublisher) Close(ctx context.Context) error {
+	p.m.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.m.Unlock()
+
+	select {
+	case <-p.done:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		<-p.done
+		return ctx.Err()
+	}
+}
+
+// Stats returns the current state of the publisher
+func (p *EventPublisher) Stats() EventPublisherStats {
+	return EventPublisherStats{
+		Queued:    len(p.queue),
+		InFlight:  int(p.inFlight.Load()),
+		Published: p.published.Load(),
+		Failed:    p.failed.Load(),
+		Dropped:   p.dropped.Load(),
+	}
+}
+
+func (p *EventPublisher) work() {
+	for event := range p.queue {
+		p.inFlight.Add(1)
+		err := p.send(event)
+		p.inFlight.Add(-1)
+		if err != nil {
+			if p.ctx.Err() != nil {
+				p.dropped.Add(1)
+			} else {
+				p.failed.Add(1)
+			}
+			p.report(event, err)
+		} else {
+			p.published.Add(1)
+		}
+
+		p.m.Lock()
+		p.pending--
+		if p.pending == 0 {
+			close(p.idle)
+		}
+		p.m.Unlock()
+	}
+}
+
+// send creates the event, retrying retryable errors, including an attempt
+// timing out
+func (p *EventPublisher) send(event *Event) error {
+	for attempt := 1; ; attempt++ {
+		err := p.create(event)
+		if err == nil || attempt >= p.config.RetryPolicy.MaxAttempts || p.ctx.Err() != nil {
+			return err
+		}
+		if !IsRetryable(err) && !errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		err = sleepContext(p.ctx, p.config.RetryPolicy.backoff(attempt))
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (p *EventPublisher) create(event *Event) error {
+	ctx := p.ctx
+	if p.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.config.Timeout)
+		defer cancel()
+	}
+	_, err := p.client.Events.CreateContext(ctx, event, nil)
+	return err
+}
+
+func (p *EventPublisher) drop(event *Event, err error) {
+	p.dropped.Add(1)
+	p.report(event, err)
+}
+
+func (p *EventPublisher) report(event *Event, err error) {
+	if p.config.OnError != nil {
+		p.config.OnError(event, err)
+	}
+}
@@ -0,0 +1,205 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package luno_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mschoch/luno-go"
+	"github.com/mschoch/luno-go/lunotest"
+)
+
+func testPublisherConfig() luno.EventPublisherConfig {
+	config := luno.DefaultEventPublisherConfig
+	config.RetryPolicy.BaseDelay = time.Millisecond
+	config.RetryPolicy.MaxDelay = time.Millisecond
+	return config
+}
+
+func lunoErrorResponse(req *http.Request, status int, code string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader(`{"code":"` + code + `"}`)),
+		Request:    req,
+	}
+}
+
+func TestEventPublisher(t *testing.T) {
+	lunoClient, _ := lunotest.NewClient(t)
+	publisher := luno.NewEventPublisher(lunoClient, testPublisherConfig())
+
+	for i := 0; i < 20; i++ {
+		err := publisher.Publish(&luno.Event{Name: "Page View"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := publisher.Flush(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := publisher.Stats()
+	if stats.Published != 20 || stats.Queued != 0 || stats.InFlight != 0 {
+		t.Errorf("expected 20 published events, got %+v", stats)
+	}
+	events, err := lunoClient.Events.Recent(nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events.List) != 20 {
+		t.Errorf("expected 20 events, got %d", len(events.List))
+	}
+
+	err = publisher.Close(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = publisher.Publish(&luno.Event{Name: "Too Late"})
+	if !errors.Is(err, luno.ErrPublisherClosed) {
+		t.Errorf("expected publisher closed, got %v", err)
+	}
+}
+
+func TestEventPublisherRetries(t *testing.T) {
+	var calls atomic.Int32
+	failing := func(next luno.Doer) luno.Doer {
+		return luno.DoerFunc(func(req *http.Request) (*http.Response, error) {
+			if luno.OperationFromContext(req.Context()) == "events.create" {
+				if calls.Add(1) <= 2 {
+					return lunoErrorResponse(req, http.StatusServiceUnavailable, luno.ErrCodeServiceUnavailable), nil
+				}
+			}
+			return next.Do(req)
+		})
+	}
+	lunoClient, _ := lunotest.NewClient(t, luno.WithMiddleware(failing))
+
+	var m sync.Mutex
+	var reported []error
+	config := testPublisherConfig()
+	config.Workers = 1
+	config.OnError = func(event *luno.Event, err error) {
+		m.Lock()
+		reported = append(reported, err)
+		m.Unlock()
+	}
+	publisher := luno.NewEventPublisher(lunoClient, config)
+	err := publisher.Publish(&luno.Event{Name: "Retried"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = publisher.Close(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := publisher.Stats()
+	if stats.Published != 1 || stats.Failed != 0 || calls.Load() != 3 {
+		t.Errorf("expected event published on third attempt, got %+v after %d calls", stats, calls.Load())
+	}
+	if len(reported) != 0 {
+		t.Errorf("expected no errors reported, got %v", reported)
+	}
+}
+
+func TestEventPublisherFailures(t *testing.T) {
+	rejecting := func(next luno.Doer) luno.Doer {
+		return luno.DoerFunc(func(req *http.Request) (*http.Response, error) {
+			return lunoErrorResponse(req, http.StatusBadRequest, luno.ErrCodeInvalidParams), nil
+		})
+	}
+	lunoClient, _ := lunotest.NewClient(t, luno.WithMiddleware(rejecting))
+
+	var m sync.Mutex
+	var reported []error
+	config := testPublisherConfig()
+	config.OnError = func(event *luno.Event, err error) {
+		m.Lock()
+		reported = append(reported, err)
+		m.Unlock()
+	}
+	publisher := luno.NewEventPublisher(lunoClient, config)
+	defer func() { _ = publisher.Close(context.Background()) }()
+
+	err := publisher.Publish(&luno.Event{Name: "Rejected"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = publisher.Flush(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := publisher.Stats(); stats.Failed != 1 {
+		t.Errorf("expected 1 failed event, got %+v", stats)
+	}
+	m.Lock()
+	defer m.Unlock()
+	if len(reported) != 1 || !luno.IsErrorCode(reported[0], luno.ErrCodeInvalidParams) {
+		t.Errorf("expected invalid params to be reported, got %v", reported)
+	}
+}
+
+func TestEventPublisherQueueFullAndCloseDeadline(t *testing.T) {
+	blocking := func(next luno.Doer) luno.Doer {
+		return luno.DoerFunc(func(req *http.Request) (*http.Response, error) {
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		})
+	}
+	lunoClient, _ := lunotest.NewClient(t, luno.WithMiddleware(blocking))
+
+	var dropped atomic.Int32
+	config := testPublisherConfig()
+	config.QueueSize = 1
+	config.Workers = 1
+	config.OnError = func(event *luno.Event, err error) {
+		dropped.Add(1)
+	}
+	publisher := luno.NewEventPublisher(lunoClient, config)
+
+	err := publisher.Publish(&luno.Event{Name: "Blocked"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for publisher.Stats().InFlight == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	err = publisher.Publish(&luno.Event{Name: "Queued"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := publisher.Stats(); stats.Queued != 1 {
+		t.Errorf("expected 1 queued event, got %+v", stats)
+	}
+	err = publisher.Publish(&luno.Event{Name: "Overflow"})
+	if !errors.Is(err, luno.ErrPublisherQueueFull) {
+		t.Errorf("expected queue full, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = publisher.Close(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected close to hit its deadline, got %v", err)
+	}
+	if stats := publisher.Stats(); stats.Dropped != 3 || dropped.Load() != 3 {
+		t.Errorf("expected 3 dropped events, got %+v and %d reported", stats, dropped.Load())
+	}
+}
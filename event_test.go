@@ -9,19 +9,19 @@
 //  express or implied. See the License for the specific language
 //  governing permissions and limitations under the License.
 
-package luno
+package luno_test
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/mschoch/luno-go"
+)
 
 func TestEvents(t *testing.T) {
-	apiKey, secretKey, err := lunoKeysFromEnv()
-	if err != nil {
-		t.Fatal(err)
-	}
-	lunoClient := NewClient(apiKey, secretKey)
+	lunoClient := newTestClient(t)
 
 	// now create a user
-	user := &User{
+	user := &luno.User{
 		Name:     "Charles Winchester",
 		Email:    "chuck@af.com",
 		Password: "imrich",
@@ -31,7 +31,7 @@ func TestEvents(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	events, err := lunoClient.Events.Recent(nil, &EventFilter{UserID: createdUser.ID}, nil)
+	events, err := lunoClient.Events.Recent(nil, &luno.EventFilter{UserID: createdUser.ID}, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -45,7 +45,7 @@ func TestEvents(t *testing.T) {
 	}
 
 	// now create a custom event
-	customEvent := &Event{
+	customEvent := &luno.Event{
 		UserID: createdUser.ID,
 		Name:   "bad_hat",
 	}
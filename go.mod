@@ -0,0 +1,3 @@
+module github.com/mschoch/luno-go
+
+go 1.24
@@ -0,0 +1,34 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package luno_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/mschoch/luno-go"
+	"github.com/mschoch/luno-go/lunotest"
+)
+
+// newTestClient returns a client for the live Luno API when LUNO_API_KEY and
+// LUNO_SECRET_KEY are set, otherwise a client for a new lunotest server
+func newTestClient(t *testing.T) *luno.Client {
+	if os.Getenv(luno.EnvAPIKey) != "" && os.Getenv(luno.EnvSecretKey) != "" {
+		lunoClient, err := luno.LoadClient()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return lunoClient
+	}
+	lunoClient, _ := lunotest.NewClient(t)
+	return lunoClient
+}
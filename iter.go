@@ -0,0 +1,125 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package luno
+
+import (
+	"context"
+	"iter"
+)
+
+// pageFetcher fetches a single page of items
+type pageFetcher[T any] func(ctx context.Context, paging *Paging) ([]T, Page, error)
+
+// Iterator walks every item in a paged Luno list, following the next page
+// until the list is exhausted, an error occurs, the context is done or the
+// maximum number of items is reached.
+//
+//	it := client.Users.Iter(ctx, nil, nil)
+//	for it.Next() {
+//		user := it.Value()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+type Iterator[T any] struct {
+	ctx     context.Context
+	fetch   pageFetcher[T]
+	paging  *Paging
+	limit   int
+	max     int
+	started bool
+	items   []T
+	pos     int
+	count   int
+	cur     T
+	err     error
+}
+
+func newIterator[T any](ctx context.Context, paging *Paging, fetch pageFetcher[T]) *Iterator[T] {
+	rv := &Iterator[T]{
+		ctx:    ctx,
+		fetch:  fetch,
+		paging: paging,
+	}
+	if paging != nil {
+		rv.limit = paging.Limit
+	}
+	return rv
+}
+
+// Max limits the iterator to at most n items, n <= 0 means no limit
+func (it *Iterator[T]) Max(n int) *Iterator[T] {
+	it.max = n
+	return it
+}
+
+// Next advances to the next item, fetching the next page if required.  It
+// returns false when there are no more items or an error occurred.
+func (it *Iterator[T]) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if it.max > 0 && it.count >= it.max {
+		return false
+	}
+	if err := it.ctx.Err(); err != nil {
+		it.err = err
+		return false
+	}
+	for it.pos >= len(it.items) {
+		if it.started && it.paging == nil {
+			return false
+		}
+		items, page, err := it.fetch(it.ctx, it.paging)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.started = true
+		it.items = items
+		it.pos = 0
+		it.paging = page.NextPaging(it.limit)
+		if len(items) == 0 {
+			// an empty page means the list is exhausted
+			it.paging = nil
+		}
+	}
+	it.cur = it.items[it.pos]
+	it.pos++
+	it.count++
+	return true
+}
+
+// Value returns the current item
+func (it *Iterator[T]) Value() T {
+	return it.cur
+}
+
+// Err returns the error, if any, which stopped the iteration
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// All returns an iter.Seq2 over the remaining items, suitable for use with
+// range.  If an error stops the iteration it is yielded with the zero item.
+func (it *Iterator[T]) All() iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for it.Next() {
+			if !yield(it.Value(), nil) {
+				return
+			}
+		}
+		if err := it.Err(); err != nil {
+			var zero T
+			yield(zero, err)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package luno
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// pagedServer serves total sessions, limit per page, recording the query of
+// every request
+func pagedServer(total int, queries *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		*queries = append(*queries, fmt.Sprintf("from=%s limit=%s expand=%s user_id=%s",
+			q.Get("from"), q.Get("limit"), q.Get("expand"), q.Get("user_id")))
+		if r.URL.Path != "/v1/sessions" {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{"code":"internal","status":500}`))
+			return
+		}
+		from, _ := strconv.Atoi(q.Get("from"))
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		if limit <= 0 {
+			limit = 2
+		}
+		rv := Sessions{}
+		for i := from; i < from+limit && i < total; i++ {
+			rv.List = append(rv.List, &Session{Entity: Entity{ID: strconv.Itoa(i)}})
+		}
+		if from+limit < total {
+			rv.Page.Next = Entity{URL: fmt.Sprintf("/v1/sessions?from=%d&limit=%d", from+limit, limit)}
+		}
+		_ = json.NewEncoder(w).Encode(&rv)
+	}))
+}
+
+func TestIterator(t *testing.T) {
+	var queries []string
+	server := pagedServer(5, &queries)
+	defer server.Close()
+	lunoClient := NewClient("key", "secret", WithBaseURL(server.URL))
+
+	it := lunoClient.Sessions.Iter(context.Background(), []string{"user"}, &SessionFilter{UserID: "usr_1"}, &Paging{Limit: 2})
+	var ids []string
+	for it.Next() {
+		ids = append(ids, it.Value().ID)
+	}
+	if it.Err() != nil {
+		t.Fatal(it.Err())
+	}
+	if fmt.Sprint(ids) != "[0 1 2 3 4]" {
+		t.Errorf("expected ids [0 1 2 3 4], got %v", ids)
+	}
+	expected := "[from= limit=2 expand=user user_id=usr_1 from=2 limit=2 expand=user user_id=usr_1 from=4 limit=2 expand=user user_id=usr_1]"
+	if fmt.Sprint(queries) != expected {
+		t.Errorf("expected queries %s, got %v", expected, queries)
+	}
+}
+
+func TestIteratorMaxItems(t *testing.T) {
+	var queries []string
+	server := pagedServer(100, &queries)
+	defer server.Close()
+	lunoClient := NewClient("key", "secret", WithBaseURL(server.URL))
+
+	count := 0
+	for session, err := range lunoClient.Sessions.Iter(context.Background(), nil, nil, &Paging{Limit: 2}).Max(3).All() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if session.ID != strconv.Itoa(count) {
+			t.Errorf("expected session %d, got %s", count, session.ID)
+		}
+		count++
+	}
+	if count != 3 || len(queries) != 2 {
+		t.Errorf("expected 3 sessions from 2 pages, got %d from %d", count, len(queries))
+	}
+}
+
+func TestIteratorStops(t *testing.T) {
+	var queries []string
+	server := pagedServer(100, &queries)
+	defer server.Close()
+	lunoClient := NewClient("key", "secret", WithBaseURL(server.URL), WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+
+	// stop on error
+	var gotErr error
+	for _, err := range lunoClient.Users.All(context.Background(), nil, nil) {
+		gotErr = err
+	}
+	if !IsErrorCode(gotErr, "internal") {
+		t.Errorf("expected internal error, got %v", gotErr)
+	}
+
+	// stop on context cancellation
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	it := lunoClient.Sessions.Iter(ctx, nil, nil, nil)
+	count := 0
+	for it.Next() {
+		count++
+		if count == 3 {
+			cancel()
+		}
+	}
+	if it.Err() != context.Canceled {
+		t.Errorf("expected context canceled, got %v", it.Err())
+	}
+	if count != 3 {
+		t.Errorf("expected 3 sessions before cancellation, got %d", count)
+	}
+}
@@ -0,0 +1,112 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package luno
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+)
+
+// LevelTrace is the level at which request and response bodies are
+// logged, it is more verbose than slog.LevelDebug
+const LevelTrace = slog.LevelDebug - 4
+
+// redacted replaces the value of sensitive fields in logs
+const redacted = "REDACTED"
+
+// sensitiveFields are URL parameters and JSON keys whose values are never
+// logged
+var sensitiveFields = map[string]bool{
+	"password":         true,
+	"current_password": true,
+	"secret":           true,
+	"key":              true,
+	"sign":             true,
+}
+
+// WithLogger sets the logger used by the client.  Requests and responses
+// are logged at slog.LevelDebug with the operation, method, endpoint,
+// status, latency and request id, retries at slog.LevelInfo and failures
+// at slog.LevelWarn.  Bodies are logged at LevelTrace.  Passwords, secrets,
+// keys and signatures are redacted from logged URLs, errors and bodies.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// redactURL replaces the values of sensitive parameters in the query
+// string of the provided URL
+func redactURL(rawURL string) string {
+	qpos := strings.Index(rawURL, "?")
+	if qpos < 0 {
+		return rawURL
+	}
+	params, err := url.ParseQuery(rawURL[qpos+1:])
+	if err != nil {
+		return rawURL[:qpos] + "?" + redacted
+	}
+	for k := range params {
+		if sensitiveFields[k] {
+			params[k] = []string{redacted}
+		}
+	}
+	return rawURL[:qpos] + "?" + params.Encode()
+}
+
+// redactError redacts the URL of a *url.Error in the chain of err, as
+// returned by http.Client, which holds the full signed request URL.  The
+// error is modified in place and returned.
+func redactError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = redactURL(urlErr.URL)
+	}
+	return err
+}
+
+// redactJSON replaces the values of sensitive keys, at any depth, in the
+// provided JSON.  Bodies which are not valid JSON are not logged.
+func redactJSON(body []byte) string {
+	var val interface{}
+	err := json.Unmarshal(body, &val)
+	if err != nil {
+		return fmt.Sprintf("<%d bytes non-json body>", len(body))
+	}
+	rv, err := json.Marshal(redactValue(val))
+	if err != nil {
+		return fmt.Sprintf("<%d bytes unprintable body>", len(body))
+	}
+	return string(rv)
+}
+
+func redactValue(val interface{}) interface{} {
+	switch val := val.(type) {
+	case map[string]interface{}:
+		for k, v := range val {
+			if sensitiveFields[k] {
+				val[k] = redacted
+			} else {
+				val[k] = redactValue(v)
+			}
+		}
+	case []interface{}:
+		for i, v := range val {
+			val[i] = redactValue(v)
+		}
+	}
+	return val
+}
@@ -0,0 +1,116 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package luno
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogRedaction(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-Id", "req-123")
+		_, _ = w.Write([]byte(`{"user":{"id":"usr_1"},"session":{"id":"sess_1","key":"topsecretsessionkey"}}`))
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: LevelTrace}))
+	lunoClient := NewClient("myapikey", "mysecretkey", WithBaseURL(server.URL), WithLogger(logger))
+
+	_, _, err := lunoClient.Users.LoginWithEmail("bozo@clown.com", "h8clownz", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logged := buf.String()
+	for _, secret := range []string{"h8clownz", "myapikey", "mysecretkey", "topsecretsessionkey", "sign=0", "sign=1"} {
+		if strings.Contains(logged, secret) {
+			t.Errorf("expected '%s' to be redacted, got: %s", secret, logged)
+		}
+	}
+	for _, expected := range []string{`"op":"users.login"`, `"method":"POST"`, `"endpoint":"/users/login"`,
+		`"status":200`, `"request_id":"req-123"`, `"latency"`, "bozo@clown.com"} {
+		if !strings.Contains(logged, expected) {
+			t.Errorf("expected log to contain '%s', got: %s", expected, logged)
+		}
+	}
+
+	// at debug level, bodies are not logged
+	buf.Reset()
+	logger = slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	lunoClient = NewClient("myapikey", "mysecretkey", WithBaseURL(server.URL), WithLogger(logger))
+	_, _, err = lunoClient.Users.LoginWithEmail("bozo@clown.com", "h8clownz", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "bozo@clown.com") {
+		t.Errorf("expected no bodies logged at debug level, got: %s", buf.String())
+	}
+}
+
+func TestLogRedactionTransportFailure(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	lunoClient := NewClient("myapikey", "mysecretkey", WithBaseURL(server.URL), WithLogger(logger),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}))
+	_, err := lunoClient.Users.Get("usr_1")
+	if err == nil {
+		t.Fatal("expected connection refused")
+	}
+
+	logged := buf.String()
+	for _, expected := range []string{"luno request failed", "luno retrying request", "/v1/users/usr_1"} {
+		if !strings.Contains(logged, expected) {
+			t.Errorf("expected log to contain '%s', got: %s", expected, logged)
+		}
+	}
+	if strings.Contains(logged, "myapikey") || strings.Count(logged, "sign=") != strings.Count(logged, "sign="+redacted) {
+		t.Errorf("expected key and signature to be redacted, got: %s", logged)
+	}
+}
+
+func TestRedactURL(t *testing.T) {
+	tests := map[string]string{
+		"/v1/users": "/v1/users",
+		"/v1/users?key=abc&timestamp=now&sign=def": "/v1/users?key=REDACTED&sign=REDACTED&timestamp=now",
+		"/v1/users?limit=10&password=abc":          "/v1/users?limit=10&password=REDACTED",
+	}
+	for in, expected := range tests {
+		if got := redactURL(in); got != expected {
+			t.Errorf("expected '%s', got '%s'", expected, got)
+		}
+	}
+}
+
+func TestRedactJSON(t *testing.T) {
+	tests := map[string]string{
+		`{"password":"abc","current_password":"def"}`:        `{"current_password":"REDACTED","password":"REDACTED"}`,
+		`{"session":{"key":"abc","details":{"secret":"x"}}}`: `{"session":{"details":{"secret":"REDACTED"},"key":"REDACTED"}}`,
+		`[{"name":"ok"}]`: `[{"name":"ok"}]`,
+		`not json`:        `<8 bytes non-json body>`,
+	}
+	for in, expected := range tests {
+		if got := redactJSON([]byte(in)); got != expected {
+			t.Errorf("expected '%s', got '%s'", expected, got)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+// Package lunobulk exports and imports Luno users in bulk, as JSON lines or
+// CSV files, for loading into a data warehouse or migrating from another
+// system.
+package lunobulk
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Format is the file format of an export or import
+type Format string
+
+// Supported formats
+const (
+	// FormatJSONL is one JSON object per line
+	FormatJSONL Format = "jsonl"
+	// FormatCSV is comma separated values with a header row
+	FormatCSV Format = "csv"
+)
+
+func (f Format) validate() error {
+	switch f {
+	case FormatJSONL, FormatCSV:
+		return nil
+	}
+	return fmt.Errorf("unsupported format '%s', expected jsonl or csv", f)
+}
+
+// ProfileColumn maps a value in the User Profile to a column of its own
+type ProfileColumn struct {
+	// Column is the name of the column
+	Column string
+	// Path is the dotted path of the value in the profile, such as
+	// "address.city"
+	Path string
+}
+
+// userColumns are the columns of every record, before any profile columns
+var userColumns = []string{"id", "email", "username", "name", "first_name", "last_name", "created", "closed"}
+
+// profileColumn is the column holding the whole profile as JSON, when no
+// ProfileColumns are configured
+const profileColumn = "profile"
+
+// lookupPath finds the value at a dotted path in untyped details
+func lookupPath(details interface{}, path string) interface{} {
+	for _, key := range strings.Split(path, ".") {
+		m, ok := details.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		details = m[key]
+	}
+	return details
+}
+
+// formatValue formats an untyped value for a CSV cell, strings are
+// unquoted and other values are JSON
+func formatValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling profile value: %v", err)
+	}
+	return string(data), nil
+}
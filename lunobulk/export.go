@@ -0,0 +1,372 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package lunobulk
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/mschoch/luno-go"
+)
+
+// DefaultPageSize is the number of users requested per page
+const DefaultPageSize = 100
+
+// ExportConfig configures an Exporter
+type ExportConfig struct {
+	// Format of the output, the default is FormatJSONL
+	Format Format
+	// Expand is passed to Users.Recent
+	Expand []string
+	// PageSize is the number of users requested per page, the default is
+	// DefaultPageSize
+	PageSize int
+	// Profile flattens values of the User Profile into columns.  When
+	// empty, JSONL records hold the whole user and CSV records hold the
+	// profile as JSON in a profile column.
+	Profile []ProfileColumn
+	// Checkpoint is the path of the checkpoint file, when set the export
+	// records its progress after each page and resumes from it
+	Checkpoint string
+	// Progress, if set, is called after each page is written
+	Progress func(ExportStats)
+}
+
+// ExportStats reports the progress of an export, including any pages
+// exported before it was resumed
+type ExportStats struct {
+	Pages int
+	Users int
+	// Cursor is the id the next page starts from, empty when done
+	Cursor string
+	Done   bool
+}
+
+// Checkpoint records how far an export got, so that it can be resumed
+type Checkpoint struct {
+	Format  Format   `json:"format"`
+	Columns []string `json:"columns,omitempty"`
+	Cursor  string   `json:"cursor"`
+	Pages   int      `json:"pages"`
+	Users   int      `json:"users"`
+	// Offset is the number of bytes of output written up to the cursor
+	Offset int64 `json:"offset"`
+}
+
+// LoadCheckpoint reads a checkpoint file, returning nil if it does not
+// exist
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading checkpoint: %v", err)
+	}
+	var rv Checkpoint
+	err = json.Unmarshal(data, &rv)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing checkpoint json %s: %v", path, err)
+	}
+	return &rv, nil
+}
+
+// Save writes the checkpoint atomically, replacing any previous one
+func (c *Checkpoint) Save(path string) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("error marshaling checkpoint json: %v", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("error writing checkpoint: %v", err)
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("error writing checkpoint: %v", err)
+	}
+	return nil
+}
+
+// Exporter streams every Luno user to JSONL or CSV, following the pages
+// of Users.Recent from the newest user.  Users created while an export
+// runs may be missed, they are included in the next export.
+type Exporter struct {
+	client *luno.Client
+	config ExportConfig
+}
+
+// NewExporter builds an Exporter using the provided client
+func NewExporter(client *luno.Client, config ExportConfig) *Exporter {
+	if config.Format == "" {
+		config.Format = FormatJSONL
+	}
+	if config.PageSize <= 0 {
+		config.PageSize = DefaultPageSize
+	}
+	return &Exporter{client: client, config: config}
+}
+
+// columns returns the CSV header, or nil for JSONL records of whole users
+func (e *Exporter) columns() []string {
+	if e.config.Format == FormatJSONL && len(e.config.Profile) == 0 {
+		return nil
+	}
+	rv := slices.Clone(userColumns)
+	if len(e.config.Profile) == 0 {
+		return append(rv, profileColumn)
+	}
+	for _, column := range e.config.Profile {
+		rv = append(rv, column.Column)
+	}
+	return rv
+}
+
+// ExportFile exports to the file at path.  If a checkpoint exists the
+// file is truncated to the checkpoint and the export resumes, otherwise
+// the file is replaced.
+func (e *Exporter) ExportFile(ctx context.Context, path string) (ExportStats, error) {
+	checkpoint, err := e.loadCheckpoint()
+	if err != nil {
+		return ExportStats{}, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return ExportStats{}, fmt.Errorf("error opening export file: %v", err)
+	}
+	var offset int64
+	if checkpoint != nil {
+		offset = checkpoint.Offset
+	}
+	err = f.Truncate(offset)
+	if err == nil {
+		_, err = f.Seek(offset, io.SeekStart)
+	}
+	if err != nil {
+		_ = f.Close()
+		return ExportStats{}, fmt.Errorf("error preparing export file: %v", err)
+	}
+	stats, err := e.export(ctx, f, checkpoint)
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("error closing export file: %v", closeErr)
+	}
+	return stats, err
+}
+
+// Export writes every user to w.  If a checkpoint exists the export
+// resumes from it, in which case w must hold the output up to the
+// checkpoint Offset, see ExportFile.  The checkpoint is removed when the
+// export completes.
+func (e *Exporter) Export(ctx context.Context, w io.Writer) (ExportStats, error) {
+	checkpoint, err := e.loadCheckpoint()
+	if err != nil {
+		return ExportStats{}, err
+	}
+	return e.export(ctx, w, checkpoint)
+}
+
+func (e *Exporter) loadCheckpoint() (*Checkpoint, error) {
+	err := e.config.Format.validate()
+	if err != nil {
+		return nil, err
+	}
+	if e.config.Checkpoint == "" {
+		return nil, nil
+	}
+	checkpoint, err := LoadCheckpoint(e.config.Checkpoint)
+	if err != nil || checkpoint == nil {
+		return nil, err
+	}
+	if checkpoint.Format != e.config.Format || !slices.Equal(checkpoint.Columns, e.columns()) {
+		return nil, fmt.Errorf("checkpoint %s is for a %s export with different columns, remove it to start again",
+			e.config.Checkpoint, checkpoint.Format)
+	}
+	return checkpoint, nil
+}
+
+func (e *Exporter) export(ctx context.Context, w io.Writer, checkpoint *Checkpoint) (ExportStats, error) {
+	if checkpoint == nil {
+		checkpoint = &Checkpoint{Format: e.config.Format, Columns: e.columns()}
+	}
+	stats := ExportStats{Pages: checkpoint.Pages, Users: checkpoint.Users, Cursor: checkpoint.Cursor}
+	out := &countingWriter{w: w, n: checkpoint.Offset}
+	rw := newRecordWriter(out, e.config.Format, checkpoint.Columns, e.config.Profile)
+	if checkpoint.Offset == 0 {
+		err := rw.writeHeader()
+		if err != nil {
+			return stats, err
+		}
+	}
+
+	paging := &luno.Paging{From: checkpoint.Cursor, Limit: e.config.PageSize}
+	for {
+		users, err := e.client.Users.RecentContext(ctx, e.config.Expand, paging)
+		if err != nil {
+			return stats, err
+		}
+		for _, user := range users.List {
+			err = rw.write(user)
+			if err != nil {
+				return stats, err
+			}
+		}
+		err = rw.flush()
+		if err != nil {
+			return stats, err
+		}
+		if syncer, ok := w.(interface{ Sync() error }); ok {
+			err = syncer.Sync()
+			if err != nil {
+				return stats, fmt.Errorf("error syncing export: %v", err)
+			}
+		}
+
+		paging = users.Page.NextPaging(e.config.PageSize)
+		if len(users.List) == 0 {
+			paging = nil
+		}
+		stats.Pages++
+		stats.Users += len(users.List)
+		stats.Cursor = ""
+		if paging != nil {
+			stats.Cursor = paging.From
+		}
+		stats.Done = paging == nil
+
+		if e.config.Checkpoint != "" {
+			if stats.Done {
+				err = os.Remove(e.config.Checkpoint)
+				if err != nil && !os.IsNotExist(err) {
+					return stats, fmt.Errorf("error removing checkpoint: %v", err)
+				}
+			} else {
+				checkpoint.Cursor = stats.Cursor
+				checkpoint.Pages = stats.Pages
+				checkpoint.Users = stats.Users
+				checkpoint.Offset = out.n
+				err = checkpoint.Save(e.config.Checkpoint)
+				if err != nil {
+					return stats, err
+				}
+			}
+		}
+		if e.config.Progress != nil {
+			e.config.Progress(stats)
+		}
+		if stats.Done {
+			return stats, nil
+		}
+	}
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// recordWriter writes users as JSONL or CSV records
+type recordWriter struct {
+	buf     *bufio.Writer
+	csv     *csv.Writer
+	columns []string
+	profile []ProfileColumn
+}
+
+func newRecordWriter(w io.Writer, format Format, columns []string, profile []ProfileColumn) *recordWriter {
+	rv := &recordWriter{buf: bufio.NewWriter(w), columns: columns, profile: profile}
+	if format == FormatCSV {
+		rv.csv = csv.NewWriter(rv.buf)
+	}
+	return rv
+}
+
+func (r *recordWriter) writeHeader() error {
+	if r.csv == nil {
+		return nil
+	}
+	return r.csv.Write(r.columns)
+}
+
+func (r *recordWriter) write(user *luno.User) error {
+	values := []interface{}{user.ID, user.Email, user.UserName, user.Name, user.FirstName, user.LastName,
+		user.Created.String(), user.Closed.String()}
+	if len(r.profile) == 0 {
+		values = append(values, user.Profile)
+	}
+	for _, column := range r.profile {
+		values = append(values, lookupPath(user.Profile, column.Path))
+	}
+
+	if r.csv == nil {
+		var record interface{} = user
+		if r.columns != nil {
+			object := make(map[string]interface{}, len(values))
+			for i, column := range r.columns {
+				object[column] = values[i]
+			}
+			record = object
+		}
+		data, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("error marshaling user json: %v", err)
+		}
+		_, err = r.buf.Write(append(data, '\n'))
+		return err
+	}
+
+	row := make([]string, len(values))
+	for i, value := range values {
+		var err error
+		row[i], err = formatValue(value)
+		if err != nil {
+			return err
+		}
+	}
+	return r.csv.Write(row)
+}
+
+func (r *recordWriter) flush() error {
+	if r.csv != nil {
+		r.csv.Flush()
+		if err := r.csv.Error(); err != nil {
+			return fmt.Errorf("error writing csv: %v", err)
+		}
+	}
+	err := r.buf.Flush()
+	if err != nil {
+		return fmt.Errorf("error writing export: %v", err)
+	}
+	return nil
+}
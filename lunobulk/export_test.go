@@ -0,0 +1,218 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package lunobulk_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mschoch/luno-go"
+	"github.com/mschoch/luno-go/lunobulk"
+	"github.com/mschoch/luno-go/lunotest"
+)
+
+// createUsers creates n users with a profile, returning their ids newest
+// first, the order they are exported in
+func createUsers(t *testing.T, lunoClient *luno.Client, n int) []string {
+	t.Helper()
+	ids := make([]string, n)
+	for i := 0; i < n; i++ {
+		user, err := lunoClient.Users.Create(&luno.User{
+			Email: fmt.Sprintf("user%d@example.com", i),
+			Name:  fmt.Sprintf("User %d", i),
+			Profile: map[string]interface{}{
+				"plan":    "free",
+				"address": map[string]interface{}{"city": fmt.Sprintf("City %d", i)},
+				"logins":  i,
+			},
+		}, true, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[n-1-i] = user.ID
+	}
+	return ids
+}
+
+func TestExportJSONL(t *testing.T) {
+	lunoClient, _ := lunotest.NewClient(t)
+	ids := createUsers(t, lunoClient, 25)
+
+	var progress []lunobulk.ExportStats
+	exporter := lunobulk.NewExporter(lunoClient, lunobulk.ExportConfig{
+		PageSize: 10,
+		Progress: func(stats lunobulk.ExportStats) {
+			progress = append(progress, stats)
+		},
+	})
+	var buf bytes.Buffer
+	stats, err := exporter.Export(context.Background(), &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Users != 25 || !stats.Done {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if len(progress) != 3 || progress[0].Users != 10 || progress[0].Cursor != ids[10] {
+		t.Errorf("unexpected progress %+v", progress)
+	}
+
+	scanner := bufio.NewScanner(&buf)
+	var got []string
+	for scanner.Scan() {
+		var user luno.User
+		err = json.Unmarshal(scanner.Bytes(), &user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.Profile == nil {
+			t.Errorf("expected profile for %s", user.ID)
+		}
+		got = append(got, user.ID)
+	}
+	if strings.Join(got, ",") != strings.Join(ids, ",") {
+		t.Errorf("expected users %v, got %v", ids, got)
+	}
+}
+
+func TestExportCSVProfileColumns(t *testing.T) {
+	lunoClient, _ := lunotest.NewClient(t)
+	ids := createUsers(t, lunoClient, 3)
+
+	exporter := lunobulk.NewExporter(lunoClient, lunobulk.ExportConfig{
+		Format: lunobulk.FormatCSV,
+		Profile: []lunobulk.ProfileColumn{
+			{Column: "plan", Path: "plan"},
+			{Column: "city", Path: "address.city"},
+			{Column: "logins", Path: "logins"},
+			{Column: "missing", Path: "address.zip"},
+		},
+	})
+	var buf bytes.Buffer
+	_, err := exporter.Export(context.Background(), &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantHeader := "id,email,username,name,first_name,last_name,created,closed,plan,city,logins,missing"
+	if len(records) != 4 || strings.Join(records[0], ",") != wantHeader {
+		t.Fatalf("unexpected csv %v", records)
+	}
+	first := records[1]
+	if first[0] != ids[0] || first[1] != "user2@example.com" || first[4] != "User" || first[5] != "2" {
+		t.Errorf("unexpected user columns %v", first)
+	}
+	if first[8] != "free" || first[9] != "City 2" || first[10] != "2" || first[11] != "" {
+		t.Errorf("unexpected profile columns %v", first)
+	}
+}
+
+func TestExportFileResume(t *testing.T) {
+	var failed atomic.Bool
+	failOnce := func(next luno.Doer) luno.Doer {
+		return luno.DoerFunc(func(req *http.Request) (*http.Response, error) {
+			if luno.OperationFromContext(req.Context()) == "users.recent" &&
+				strings.Contains(req.URL.String(), "from=") && failed.CompareAndSwap(false, true) {
+				return nil, errors.New("connection reset")
+			}
+			return next.Do(req)
+		})
+	}
+	lunoClient, _ := lunotest.NewClient(t, luno.WithMiddleware(failOnce),
+		luno.WithRetryPolicy(luno.RetryPolicy{MaxAttempts: 1}))
+	ids := createUsers(t, lunoClient, 12)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "users.csv")
+	checkpointPath := filepath.Join(dir, "users.checkpoint")
+	config := lunobulk.ExportConfig{
+		Format:     lunobulk.FormatCSV,
+		PageSize:   5,
+		Checkpoint: checkpointPath,
+	}
+	_, err := lunobulk.NewExporter(lunoClient, config).ExportFile(context.Background(), path)
+	if err == nil {
+		t.Fatal("expected the second page to fail")
+	}
+	checkpoint, err := lunobulk.LoadCheckpoint(checkpointPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint == nil || checkpoint.Cursor != ids[5] || checkpoint.Users != 5 {
+		t.Fatalf("unexpected checkpoint %+v", checkpoint)
+	}
+
+	// a partial page written after the checkpoint is discarded
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.WriteString("partial,row\n")
+	if err == nil {
+		err = f.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := lunobulk.NewExporter(lunoClient, config).ExportFile(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Users != 12 || stats.Pages != 3 || !stats.Done {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if _, err = os.Stat(checkpointPath); !os.IsNotExist(err) {
+		t.Errorf("expected checkpoint to be removed, got %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 13 {
+		t.Fatalf("expected header and 12 users, got %d records", len(records))
+	}
+	for i, id := range ids {
+		if records[i+1][0] != id {
+			t.Errorf("record %d expected %s, got %s", i+1, id, records[i+1][0])
+		}
+	}
+
+	// a checkpoint for different columns is rejected
+	err = (&lunobulk.Checkpoint{Format: lunobulk.FormatJSONL, Cursor: ids[5]}).Save(checkpointPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = lunobulk.NewExporter(lunoClient, config).ExportFile(context.Background(), path)
+	if err == nil || !strings.Contains(err.Error(), "different columns") {
+		t.Errorf("expected checkpoint mismatch, got %v", err)
+	}
+}
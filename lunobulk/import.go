@@ -0,0 +1,540 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package lunobulk
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mschoch/luno-go"
+)
+
+// DefaultImportWorkers is the number of records imported concurrently
+const DefaultImportWorkers = 4
+
+// ConflictPolicy decides what happens to a record whose email address is
+// already taken by an existing user
+type ConflictPolicy string
+
+// Supported conflict policies
+const (
+	// ConflictSkip leaves the existing user unchanged
+	ConflictSkip ConflictPolicy = "skip"
+	// ConflictUpdate updates the existing user with the fields of the
+	// record, merging the profile, the password is not changed
+	ConflictUpdate ConflictPolicy = "update"
+	// ConflictFail fails the record
+	ConflictFail ConflictPolicy = "fail"
+)
+
+// Action is the outcome of importing a record
+type Action string
+
+// Import actions
+const (
+	ActionCreated Action = "created"
+	ActionUpdated Action = "updated"
+	ActionSkipped Action = "skipped"
+	ActionFailed  Action = "failed"
+)
+
+// ImportConfig configures an Importer
+type ImportConfig struct {
+	// Format of the input, the default is FormatJSONL
+	Format Format
+	// Workers is the number of records imported concurrently, the default
+	// is DefaultImportWorkers
+	Workers int
+	// RecordsPerSecond limits the rate of import, with bursts of up to
+	// Burst records, zero means no limit
+	RecordsPerSecond float64
+	Burst            int
+	// Conflict is the policy for records whose email is taken, the
+	// default is ConflictSkip
+	Conflict ConflictPolicy
+	// DryRun validates the records and reports what would happen,
+	// without creating or updating users.  Of records with the same email,
+	// the first looked up is reported as created and the others as
+	// conflicts, with more than one worker which one that is depends on
+	// scheduling.
+	DryRun bool
+	// AutoName derives the first and last name from the name, for records
+	// without them
+	AutoName bool
+	// Profile maps columns to values in the User Profile, the reverse of
+	// ExportConfig.Profile
+	Profile []ProfileColumn
+	// OnResult, if set, is called with the result of each record as it
+	// completes, it may be called concurrently
+	OnResult func(ImportResult)
+}
+
+// ImportResult is the outcome of importing one record
+type ImportResult struct {
+	// Line is the line of the record in the input
+	Line  int
+	Email string
+	// ID is the id of the created or existing user, when known
+	ID     string
+	Action Action
+	Err    error
+	DryRun bool
+}
+
+// ImportReport holds the result of every record, ordered by line
+type ImportReport struct {
+	Results []ImportResult
+	Created int
+	Updated int
+	Skipped int
+	Failed  int
+}
+
+func (r *ImportReport) add(result ImportResult) {
+	r.Results = append(r.Results, result)
+	switch result.Action {
+	case ActionCreated:
+		r.Created++
+	case ActionUpdated:
+		r.Updated++
+	case ActionSkipped:
+		r.Skipped++
+	case ActionFailed:
+		r.Failed++
+	}
+}
+
+// WriteCSV writes the report as CSV, one row per record
+func (r *ImportReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"line", "email", "action", "id", "dry_run", "error"})
+	for _, result := range r.Results {
+		var errMsg string
+		if result.Err != nil {
+			errMsg = result.Err.Error()
+		}
+		_ = cw.Write([]string{strconv.Itoa(result.Line), result.Email, string(result.Action), result.ID,
+			strconv.FormatBool(result.DryRun), errMsg})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("error writing import report: %v", err)
+	}
+	return nil
+}
+
+// Importer creates Luno users from JSONL or CSV records, such as those
+// written by an Exporter.  Records hold the columns of an export, the id,
+// created and closed columns are ignored and a password column may be
+// added.
+//
+// The existing user of a conflicting record is found with
+// Users.GetByEmail.
+type Importer struct {
+	client  *luno.Client
+	config  ImportConfig
+	limiter *luno.RateLimiter
+}
+
+// reservations are the lowercase emails a dry run would create, they are
+// kept for a single Import
+type reservations struct {
+	m      sync.Mutex
+	emails map[string]bool
+}
+
+// NewImporter builds an Importer using the provided client
+func NewImporter(client *luno.Client, config ImportConfig) *Importer {
+	if config.Format == "" {
+		config.Format = FormatJSONL
+	}
+	if config.Workers <= 0 {
+		config.Workers = DefaultImportWorkers
+	}
+	if config.Conflict == "" {
+		config.Conflict = ConflictSkip
+	}
+	return &Importer{
+		client:  client,
+		config:  config,
+		limiter: luno.NewRateLimiter(config.RecordsPerSecond, config.Burst),
+	}
+}
+
+// ImportFile imports the records of the file at path
+func (i *Importer) ImportFile(ctx context.Context, path string) (*ImportReport, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening import file: %v", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	return i.Import(ctx, f)
+}
+
+// Import imports the records read from r.  Invalid and failed records are
+// reported, an error is returned only if the input cannot be read or the
+// context is done, along with the report of the records imported so far.
+func (i *Importer) Import(ctx context.Context, r io.Reader) (*ImportReport, error) {
+	err := i.config.Format.validate()
+	if err != nil {
+		return nil, err
+	}
+	switch i.config.Conflict {
+	case ConflictSkip, ConflictUpdate, ConflictFail:
+	default:
+		return nil, fmt.Errorf("unsupported conflict policy '%s'", i.config.Conflict)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	records := make(chan *record)
+	results := make(chan ImportResult)
+	reserved := &reservations{emails: make(map[string]bool)}
+	var readErr error
+	go func() {
+		defer close(records)
+		readErr = i.read(ctx, r, records)
+	}()
+	var wg sync.WaitGroup
+	for n := 0; n < i.config.Workers; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rec := range records {
+				result := i.importRecord(ctx, rec, reserved)
+				if i.config.OnResult != nil {
+					i.config.OnResult(result)
+				}
+				results <- result
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	report := &ImportReport{}
+	for result := range results {
+		report.add(result)
+	}
+	sort.Slice(report.Results, func(a, b int) bool {
+		return report.Results[a].Line < report.Results[b].Line
+	})
+	if readErr != nil {
+		return report, readErr
+	}
+	return report, ctx.Err()
+}
+
+// record is a parsed input record, err is set if it is invalid
+type record struct {
+	line int
+	user *luno.User
+	err  error
+}
+
+// read parses records from r and sends them to records
+func (i *Importer) read(ctx context.Context, r io.Reader, records chan<- *record) error {
+	send := func(rec *record) error {
+		select {
+		case records <- rec:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if i.config.Format == FormatJSONL {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(nil, 1<<20)
+		for line := 1; scanner.Scan(); line++ {
+			if len(strings.TrimSpace(scanner.Text())) == 0 {
+				continue
+			}
+			rec := &record{line: line}
+			var values map[string]interface{}
+			rec.err = json.Unmarshal(scanner.Bytes(), &values)
+			if rec.err != nil {
+				rec.err = fmt.Errorf("invalid json: %v", rec.err)
+			} else {
+				rec.user, rec.err = i.parseUser(values)
+			}
+			err := send(rec)
+			if err != nil {
+				return err
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("error reading import: %v", err)
+		}
+		return nil
+	}
+
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return fmt.Errorf("error reading csv header: %v", err)
+	}
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		rec := &record{}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rec.line = parseErr.StartLine
+			rec.err = fmt.Errorf("invalid csv: %v", parseErr.Err)
+		} else if err != nil {
+			return fmt.Errorf("error reading import: %v", err)
+		} else {
+			rec.line, _ = cr.FieldPos(0)
+			values := make(map[string]interface{}, len(row))
+			for n, value := range row {
+				if value != "" {
+					values[header[n]] = value
+				}
+			}
+			rec.user, rec.err = i.parseUser(values)
+		}
+		err = send(rec)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// parseUser builds and validates a user from the values of a record
+func (i *Importer) parseUser(values map[string]interface{}) (*luno.User, error) {
+	user := &luno.User{}
+	var profile map[string]interface{}
+	for key, value := range values {
+		var err error
+		switch key {
+		case "id", "type", "url", "created", "closed":
+		case "email":
+			user.Email, err = stringValue(key, value)
+		case "username":
+			user.UserName, err = stringValue(key, value)
+		case "name":
+			user.Name, err = stringValue(key, value)
+		case "first_name":
+			user.FirstName, err = stringValue(key, value)
+		case "last_name":
+			user.LastName, err = stringValue(key, value)
+		case "password":
+			user.Password, err = stringValue(key, value)
+		case profileColumn:
+			if s, ok := value.(string); ok {
+				// CSV holds the profile as json
+				err = json.Unmarshal([]byte(s), &value)
+				if err != nil {
+					return nil, fmt.Errorf("invalid profile json: %v", err)
+				}
+			}
+			if value == nil {
+				continue
+			}
+			m, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("profile must be a json object")
+			}
+			if profile == nil {
+				profile = map[string]interface{}{}
+			}
+			for k, v := range m {
+				profile[k] = v
+			}
+		default:
+			column := i.profileColumn(key)
+			if column == nil {
+				return nil, fmt.Errorf("unknown column '%s'", key)
+			}
+			if profile == nil {
+				profile = map[string]interface{}{}
+			}
+			setPath(profile, column.Path, value)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if profile != nil {
+		user.Profile = profile
+	}
+
+	if user.Email == "" {
+		return nil, fmt.Errorf("email is required")
+	}
+	address, err := mail.ParseAddress(user.Email)
+	if err != nil || address.Address != user.Email {
+		return nil, fmt.Errorf("invalid email '%s'", user.Email)
+	}
+	return user, nil
+}
+
+func (i *Importer) profileColumn(name string) *ProfileColumn {
+	for n := range i.config.Profile {
+		if i.config.Profile[n].Column == name {
+			return &i.config.Profile[n]
+		}
+	}
+	return nil
+}
+
+func stringValue(key string, value interface{}) (string, error) {
+	switch value := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	}
+	return "", fmt.Errorf("%s must be a string", key)
+}
+
+// setPath sets the value at a dotted path in a profile, creating objects
+// as required
+func setPath(profile map[string]interface{}, path string, value interface{}) {
+	keys := strings.Split(path, ".")
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := profile[key].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			profile[key] = next
+		}
+		profile = next
+	}
+	profile[keys[len(keys)-1]] = value
+}
+
+// importRecord creates, updates or skips the user of a record
+func (i *Importer) importRecord(ctx context.Context, rec *record, reserved *reservations) ImportResult {
+	result := ImportResult{Line: rec.line, DryRun: i.config.DryRun}
+	if rec.user != nil {
+		result.Email = rec.user.Email
+	}
+	fail := func(err error) ImportResult {
+		result.Action = ActionFailed
+		result.Err = err
+		return result
+	}
+	if rec.err != nil {
+		return fail(rec.err)
+	}
+	err := i.limiter.Wait(ctx)
+	if err != nil {
+		return fail(err)
+	}
+	user := rec.user
+	autoName := i.config.AutoName && user.FirstName == "" && user.LastName == ""
+
+	if i.config.DryRun {
+		id, exists, err := i.lookup(ctx, user.Email, reserved)
+		if err != nil {
+			return fail(err)
+		}
+		result.ID = id
+		switch {
+		case !exists:
+			result.Action = ActionCreated
+		case i.config.Conflict == ConflictSkip:
+			result.Action = ActionSkipped
+		case i.config.Conflict == ConflictFail:
+			return fail(luno.ErrEmailTaken)
+		default:
+			result.Action = ActionUpdated
+		}
+		return result
+	}
+
+	created, err := i.client.Users.CreateContext(ctx, user, autoName, nil)
+	if err == nil {
+		result.ID = created.ID
+		result.Action = ActionCreated
+		return result
+	}
+	if !errors.Is(err, luno.ErrEmailTaken) || i.config.Conflict == ConflictFail {
+		return fail(err)
+	}
+	if i.config.Conflict == ConflictSkip {
+		result.Action = ActionSkipped
+		return result
+	}
+
+	existing, err := i.client.Users.GetByEmailContext(ctx, user.Email)
+	if err != nil {
+		return fail(err)
+	}
+	result.ID = existing.ID
+	mergeUser(existing, user)
+	err = i.client.Users.UpdateContext(ctx, existing, autoName, false)
+	if err != nil {
+		return fail(err)
+	}
+	result.Action = ActionUpdated
+	return result
+}
+
+// mergeUser copies the fields set in the record to the existing user, the
+// profile is merged by Luno
+func mergeUser(existing, user *luno.User) {
+	if user.UserName != "" {
+		existing.UserName = user.UserName
+	}
+	if user.Name != "" {
+		existing.Name = user.Name
+	}
+	if user.FirstName != "" {
+		existing.FirstName = user.FirstName
+	}
+	if user.LastName != "" {
+		existing.LastName = user.LastName
+	}
+	if user.Profile != nil {
+		existing.Profile = user.Profile
+	}
+}
+
+// lookup finds the id of the user with an email for a dry run.  Emails not
+// found are reserved, so that later duplicates in the input are reported
+// as conflicts.
+func (i *Importer) lookup(ctx context.Context, email string, reserved *reservations) (string, bool, error) {
+	user, err := i.client.Users.GetByEmailContext(ctx, email)
+	if err == nil {
+		return user.ID, true, nil
+	}
+	if !errors.Is(err, luno.ErrUserNotFound) {
+		return "", false, err
+	}
+	reserved.m.Lock()
+	defer reserved.m.Unlock()
+	key := strings.ToLower(email)
+	if reserved.emails[key] {
+		return "", true, nil
+	}
+	reserved.emails[key] = true
+	return "", false, nil
+}
@@ -0,0 +1,231 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package lunobulk_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mschoch/luno-go"
+	"github.com/mschoch/luno-go/lunobulk"
+	"github.com/mschoch/luno-go/lunotest"
+)
+
+const importJSONL = `{"email": "new@example.com", "name": "New User", "password": "secret123", "profile": {"plan": "pro"}}
+{"email": "taken@example.com", "username": "taken2", "profile": {"plan": "pro"}}
+
+{"email": "not an email"}
+{"email": "new@example.com", "name": "Duplicate"}
+{"email": "other@example.com", "nickname": "x"}
+{"email": 
+`
+
+func importReport(t *testing.T, lunoClient *luno.Client, config lunobulk.ImportConfig, input string) *lunobulk.ImportReport {
+	t.Helper()
+	report, err := lunobulk.NewImporter(lunoClient, config).Import(context.Background(), strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+func checkActions(t *testing.T, report *lunobulk.ImportReport, want map[int]lunobulk.Action) {
+	t.Helper()
+	if len(report.Results) != len(want) {
+		t.Fatalf("expected %d results, got %+v", len(want), report.Results)
+	}
+	for _, result := range report.Results {
+		if result.Action != want[result.Line] {
+			t.Errorf("line %d expected %s, got %s (%v)", result.Line, want[result.Line], result.Action, result.Err)
+		}
+	}
+}
+
+func TestImportJSONL(t *testing.T) {
+	lunoClient, _ := lunotest.NewClient(t)
+	taken, err := lunoClient.Users.Create(&luno.User{Email: "taken@example.com", UserName: "taken",
+		Profile: map[string]interface{}{"source": "legacy"}}, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a dry run reports what would happen without changes, the records run
+	// concurrently, so either of the duplicates on lines 1 and 5 is created
+	// and the other updates it
+	report := importReport(t, lunoClient, lunobulk.ImportConfig{DryRun: true, Conflict: lunobulk.ConflictUpdate}, importJSONL)
+	duplicates := []lunobulk.Action{report.Results[0].Action, report.Results[3].Action}
+	if !(duplicates[0] == lunobulk.ActionCreated && duplicates[1] == lunobulk.ActionUpdated) &&
+		!(duplicates[0] == lunobulk.ActionUpdated && duplicates[1] == lunobulk.ActionCreated) {
+		t.Errorf("expected one duplicate created and one updated, got %v", duplicates)
+	}
+	checkActions(t, report, map[int]lunobulk.Action{
+		1: report.Results[0].Action,
+		2: lunobulk.ActionUpdated,
+		4: lunobulk.ActionFailed,
+		5: report.Results[3].Action,
+		6: lunobulk.ActionFailed,
+		7: lunobulk.ActionFailed,
+	})
+	if report.Created != 1 || report.Updated != 2 || report.Failed != 3 {
+		t.Errorf("unexpected dry run report %+v", report)
+	}
+	if report.Results[1].ID != taken.ID || !report.Results[1].DryRun {
+		t.Errorf("unexpected dry run result %+v", report.Results[1])
+	}
+	users, err := lunoClient.Users.Recent(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users.List) != 1 {
+		t.Errorf("expected dry run to create no users, got %d", len(users.List))
+	}
+
+	// one worker, so the duplicate record updates the user created by line 1
+	report = importReport(t, lunoClient, lunobulk.ImportConfig{Workers: 1, Conflict: lunobulk.ConflictUpdate, AutoName: true}, importJSONL)
+	if report.Created != 1 || report.Updated != 2 || report.Failed != 3 {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if !strings.Contains(report.Results[4].Err.Error(), "unknown column 'nickname'") {
+		t.Errorf("unexpected error %v", report.Results[4].Err)
+	}
+
+	created, err := lunoClient.Users.Get(report.Results[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Name != "Duplicate" || created.FirstName != "Duplicate" || created.Profile == nil {
+		t.Errorf("expected user updated by the duplicate with auto name, got %+v", created)
+	}
+	updated, err := lunoClient.Users.Get(taken.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile := updated.Profile.(map[string]interface{})
+	if updated.UserName != "taken2" || profile["plan"] != "pro" || profile["source"] != "legacy" {
+		t.Errorf("expected updated user with merged profile, got %+v", updated)
+	}
+
+	var buf bytes.Buffer
+	err = report.WriteCSV(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "line,email,action,id,dry_run,error\n1,new@example.com,created,") {
+		t.Errorf("unexpected report csv %q", buf.String())
+	}
+}
+
+func TestImportDryRunRepeated(t *testing.T) {
+	lunoClient, _ := lunotest.NewClient(t)
+	importer := lunobulk.NewImporter(lunoClient, lunobulk.ImportConfig{DryRun: true, Conflict: lunobulk.ConflictFail})
+	input := `{"email": "new@example.com"}` + "\n"
+
+	// emails reserved by one dry run are not conflicts in the next
+	for run := 1; run <= 2; run++ {
+		report, err := importer.Import(context.Background(), strings.NewReader(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkActions(t, report, map[int]lunobulk.Action{1: lunobulk.ActionCreated})
+	}
+}
+
+func TestImportCSVConflictPolicies(t *testing.T) {
+	lunoClient, _ := lunotest.NewClient(t)
+	_, err := lunoClient.Users.Create(&luno.User{Email: "taken@example.com"}, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := "id,email,name,city,profile\n" +
+		"usr_1,taken@example.com,Taken,,\n" +
+		`usr_2,new@example.com,New,Paris,"{""plan"":""pro""}"` + "\n" +
+		"usr_3,short\n"
+	config := lunobulk.ImportConfig{
+		Format:   lunobulk.FormatCSV,
+		Conflict: lunobulk.ConflictFail,
+		Profile:  []lunobulk.ProfileColumn{{Column: "city", Path: "address.city"}},
+	}
+	report := importReport(t, lunoClient, config, input)
+	checkActions(t, report, map[int]lunobulk.Action{
+		2: lunobulk.ActionFailed,
+		3: lunobulk.ActionCreated,
+		4: lunobulk.ActionFailed,
+	})
+	if !errors.Is(report.Results[0].Err, luno.ErrEmailTaken) {
+		t.Errorf("expected email taken, got %v", report.Results[0].Err)
+	}
+	created, err := lunoClient.Users.Get(report.Results[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile, _ := luno.DetailsAs[map[string]interface{}](created.Profile)
+	if address, _ := profile["address"].(map[string]interface{}); address["city"] != "Paris" || profile["plan"] != "pro" {
+		t.Errorf("unexpected profile %v", created.Profile)
+	}
+
+	config.Conflict = lunobulk.ConflictSkip
+	report = importReport(t, lunoClient, config, input)
+	checkActions(t, report, map[int]lunobulk.Action{
+		2: lunobulk.ActionSkipped,
+		3: lunobulk.ActionSkipped,
+		4: lunobulk.ActionFailed,
+	})
+}
+
+func TestImportConcurrency(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int64
+	track := func(next luno.Doer) luno.Doer {
+		return luno.DoerFunc(func(req *http.Request) (*http.Response, error) {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				max := maxInFlight.Load()
+				if n <= max || maxInFlight.CompareAndSwap(max, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			return next.Do(req)
+		})
+	}
+	lunoClient, _ := lunotest.NewClient(t, luno.WithMiddleware(track))
+
+	var input strings.Builder
+	for i := 0; i < 40; i++ {
+		fmt.Fprintf(&input, "{\"email\": \"user%d@example.com\"}\n", i)
+	}
+	var results atomic.Int64
+	report := importReport(t, lunoClient, lunobulk.ImportConfig{
+		Workers: 3,
+		OnResult: func(lunobulk.ImportResult) {
+			results.Add(1)
+		},
+	}, input.String())
+	if report.Created != 40 || results.Load() != 40 {
+		t.Errorf("expected 40 created, got %+v", report)
+	}
+	if max := maxInFlight.Load(); max > 3 || max < 2 {
+		t.Errorf("expected up to 3 concurrent requests, got %d", max)
+	}
+	for i, result := range report.Results {
+		if result.Line != i+1 {
+			t.Fatalf("expected results ordered by line, got line %d at %d", result.Line, i)
+		}
+	}
+}
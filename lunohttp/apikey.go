@@ -0,0 +1,241 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package lunohttp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/mschoch/luno-go"
+)
+
+// DefaultTimestampSkew is how far the timestamp of a request signed with an
+// API key may be from the current time
+const DefaultTimestampSkew = luno.DefaultMaxSkew
+
+// DefaultAPIKeyCacheTTL is how long API keys looked up in Luno are cached
+const DefaultAPIKeyCacheTTL = time.Minute
+
+// maxSignedBody is the maximum size of the body of a request signed with an
+// API key
+const maxSignedBody = 10 << 20
+
+// DefaultAPIKeyCacheSize is the number of API keys cached, the least
+// recently used are evicted first
+const DefaultAPIKeyCacheSize = 10000
+
+// maxAPIKeyLength is the length of the longest API key looked up in Luno
+const maxAPIKeyLength = 128
+
+// ErrNoAPIKey is returned by AuthenticateAPIKey when the request does not
+// carry an API key and signature
+var ErrNoAPIKey = errors.New("no luno api key in request")
+
+// WithTimestampSkew sets how far the timestamp of a request signed with an
+// API key may be from the current time, older requests are rejected to
+// prevent replays
+func WithTimestampSkew(skew time.Duration) Option {
+	return func(a *Authenticator) {
+		a.timestampSkew = skew
+	}
+}
+
+// WithAPIKeyCacheTTL sets how long API keys looked up in Luno are cached,
+// zero disables caching.  Keys which do not exist are also cached, so that
+// repeated requests with an unknown key do not each reach Luno.
+func WithAPIKeyCacheTTL(ttl time.Duration) Option {
+	return func(a *Authenticator) {
+		a.apiKeyTTL = ttl
+	}
+}
+
+// WithAPIKeyCacheSize sets the number of API keys cached, including keys
+// which do not exist, the least recently used are evicted first
+func WithAPIKeyCacheSize(size int) Option {
+	return func(a *Authenticator) {
+		a.apiKeySize = size
+	}
+}
+
+type apiKeyEntry struct {
+	key     string
+	apiAuth *luno.APIAuth
+	err     error
+	expires time.Time
+}
+
+// AuthenticateAPIKey checks a request signed with the key and secret of a
+// Luno APIAuth, using the same scheme as requests to Luno itself, see
+// luno.Signer.  The APIAuth is returned with its user expanded.
+// ErrNoAPIKey is returned if the request has no key or signature.
+func (a *Authenticator) AuthenticateAPIKey(r *http.Request) (*luno.APIAuth, error) {
+	params := r.URL.Query()
+	if params.Get("key") == "" || params.Get("sign") == "" {
+		return nil, ErrNoAPIKey
+	}
+	var body []byte
+	var err error
+	if r.Body != nil {
+		body, err = io.ReadAll(http.MaxBytesReader(nil, r.Body, maxSignedBody))
+		_ = r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	verifier := luno.Verifier{MaxSkew: a.timestampSkew}
+	signed, err := verifier.Parse(r, body)
+	if err != nil {
+		return nil, err
+	}
+	apiAuth, err := a.lookupAPIKey(r.Context(), signed.Key)
+	if err != nil {
+		return nil, err
+	}
+	err = signed.Verify(apiAuth.Secret)
+	if err != nil {
+		return nil, err
+	}
+	if apiAuth.User != nil && apiAuth.User.IsClosed() {
+		return nil, luno.ErrUserClosed
+	}
+	return apiAuth, nil
+}
+
+// validAPIKey checks that a key could be a Luno API key, so that arbitrary
+// values from requests are not sent to Luno
+func validAPIKey(key string) bool {
+	if key == "" || len(key) > maxAPIKeyLength {
+		return false
+	}
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// lookupAPIKey gets the APIAuth with the provided key from Luno, or the
+// cache, keys which do not exist are also cached.  Keys which are not well
+// formed are rejected without a request to Luno.
+func (a *Authenticator) lookupAPIKey(ctx context.Context, key string) (*luno.APIAuth, error) {
+	if !validAPIKey(key) {
+		return nil, &luno.Error{Code: luno.ErrCodeInvalidAPIKey, Message: "Invalid API key", Status: http.StatusUnauthorized}
+	}
+	now := time.Now()
+	a.m.Lock()
+	if elem, ok := a.apiKeys[key]; ok {
+		entry := elem.Value.(*apiKeyEntry)
+		if now.Before(entry.expires) {
+			a.apiKeyLRU.MoveToFront(elem)
+			a.m.Unlock()
+			return copyAPIAuth(entry.apiAuth), entry.err
+		}
+		a.apiKeyLRU.Remove(elem)
+		delete(a.apiKeys, key)
+	}
+	a.m.Unlock()
+
+	apiAuth, err := a.client.APIAuth.GetContext(ctx, url.PathEscape(key), []string{"user"})
+	if err != nil && !luno.IsNotFound(err) {
+		return nil, err
+	}
+	if err == nil && apiAuth.Key != key {
+		// the key looked like the id of a different APIAuth
+		apiAuth, err = nil, luno.ErrAPIAuthNotFound
+	}
+	if a.apiKeyTTL > 0 && a.apiKeySize > 0 {
+		a.m.Lock()
+		if elem, ok := a.apiKeys[key]; ok {
+			a.apiKeyLRU.Remove(elem)
+		}
+		entry := &apiKeyEntry{key: key, apiAuth: apiAuth, err: err, expires: now.Add(a.apiKeyTTL)}
+		a.apiKeys[key] = a.apiKeyLRU.PushFront(entry)
+		for a.apiKeyLRU.Len() > a.apiKeySize {
+			oldest := a.apiKeyLRU.Back()
+			a.apiKeyLRU.Remove(oldest)
+			delete(a.apiKeys, oldest.Value.(*apiKeyEntry).key)
+		}
+		a.m.Unlock()
+	}
+	return copyAPIAuth(apiAuth), err
+}
+
+// copyAPIAuth returns a copy of apiAuth, so that handlers modifying the
+// APIAuth of a request do not modify the cache
+func copyAPIAuth(apiAuth *luno.APIAuth) *luno.APIAuth {
+	if apiAuth == nil {
+		return nil
+	}
+	rv := *apiAuth
+	if apiAuth.User != nil {
+		user := *apiAuth.User
+		rv.User = &user
+	}
+	return &rv
+}
+
+// RequireAPIKey returns middleware which only calls next for requests
+// correctly signed with an API key, see AuthenticateAPIKey.  The APIAuth is
+// available from the request context with APIAuthFromContext, and its user
+// with UserFromContext.  The only replay protection is the timestamp
+// window, luno.Verifier.MaxSkew set by WithTimestampSkew, a captured
+// request may be replayed until its timestamp falls outside it.
+func (a *Authenticator) RequireAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiAuth, err := a.AuthenticateAPIKey(r)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			if apiKeyRejected(err) {
+				a.unauthorized(w, r, err)
+			} else {
+				a.errorHandler(w, r, err)
+			}
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(withAPIAuth(r.Context(), apiAuth)))
+	})
+}
+
+// apiKeyRejected checks if err means the request was not correctly signed
+// with a valid API key, as opposed to the key not being checked
+func apiKeyRejected(err error) bool {
+	return errors.Is(err, ErrNoAPIKey) ||
+		errors.Is(err, luno.ErrInvalidAPIKey) ||
+		errors.Is(err, luno.ErrInvalidTimestamp) ||
+		errors.Is(err, luno.ErrInvalidSignature) ||
+		errors.Is(err, luno.ErrUserClosed) ||
+		luno.IsNotFound(err)
+}
+
+type apiAuthKey struct{}
+
+func withAPIAuth(ctx context.Context, apiAuth *luno.APIAuth) context.Context {
+	return context.WithValue(ctx, apiAuthKey{}, apiAuth)
+}
+
+// APIAuthFromContext returns the Luno APIAuth of a request authenticated
+// with an API key, its Details describe the key
+func APIAuthFromContext(ctx context.Context) (*luno.APIAuth, bool) {
+	apiAuth, ok := ctx.Value(apiAuthKey{}).(*luno.APIAuth)
+	return apiAuth, ok
+}
@@ -0,0 +1,213 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package lunohttp_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mschoch/luno-go"
+	"github.com/mschoch/luno-go/lunohttp"
+	"github.com/mschoch/luno-go/lunotest"
+)
+
+// apiKeyServer serves users and events for the caller of a request signed
+// with an API key, echoing back the authenticated user, or an event with
+// the details of the key
+func apiKeyServer(t *testing.T, auth *lunohttp.Authenticator) *httptest.Server {
+	server := httptest.NewServer(auth.RequireAPIKey(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _ := lunohttp.UserFromContext(r.Context())
+		apiAuth, _ := lunohttp.APIAuthFromContext(r.Context())
+		var rv interface{} = user
+		if r.Method == http.MethodPost {
+			var event luno.Event
+			err := json.NewDecoder(r.Body).Decode(&event)
+			if err != nil {
+				t.Errorf("expected signed body to be readable, got %v", err)
+			}
+			event.Details = apiAuth.Details
+			rv = &event
+			w.WriteHeader(http.StatusCreated)
+		}
+		_ = json.NewEncoder(w).Encode(rv)
+	})))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRequireAPIKey(t *testing.T) {
+	counter := 0
+	counting := func(next luno.Doer) luno.Doer {
+		return luno.DoerFunc(func(req *http.Request) (*http.Response, error) {
+			if luno.OperationFromContext(req.Context()) == "api_auth.get" {
+				counter++
+			}
+			return next.Do(req)
+		})
+	}
+	lunoClient, _ := lunotest.NewClient(t, luno.WithMiddleware(counting))
+	user, err := lunoClient.Users.Create(&luno.User{Email: "customer@example.com"}, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiAuth, err := lunoClient.APIAuth.Create(&luno.APIAuth{UserID: user.ID, Details: map[string]interface{}{"plan": "gold"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := apiKeyServer(t, lunohttp.NewAuthenticator(lunoClient))
+
+	// a customer calls our service with their key, signing like luno-go
+	customer := luno.NewClient(apiAuth.Key, apiAuth.Secret, luno.WithBaseURL(server.URL))
+	got, err := customer.Users.Get("me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != user.ID {
+		t.Errorf("expected user %s, got %s", user.ID, got.ID)
+	}
+	event, err := customer.Events.Create(&luno.Event{Name: "Signed Body"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	details, _ := event.Details.(map[string]interface{})
+	if event.Name != "Signed Body" || details["plan"] != "gold" {
+		t.Errorf("expected echoed event with key details, got %+v", event)
+	}
+	if counter != 1 {
+		t.Errorf("expected api key to be looked up once, got %d", counter)
+	}
+
+	// wrong secret
+	_, err = luno.NewClient(apiAuth.Key, "wrong", luno.WithBaseURL(server.URL)).Users.Get("me")
+	if !isStatus(err, http.StatusUnauthorized) {
+		t.Errorf("expected 401 for wrong secret, got %v", err)
+	}
+
+	// unknown key
+	_, err = luno.NewClient("unknown", "secret", luno.WithBaseURL(server.URL)).Users.Get("me")
+	if !isStatus(err, http.StatusUnauthorized) {
+		t.Errorf("expected 401 for unknown key, got %v", err)
+	}
+
+	// unsigned
+	resp, err := http.Get(server.URL + "/v1/users/me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected 401 for unsigned request, got %d", resp.StatusCode)
+	}
+}
+
+func TestRequireAPIKeyTimestamp(t *testing.T) {
+	lunoClient, _ := lunotest.NewClient(t)
+	apiAuth, err := lunoClient.APIAuth.Create(&luno.APIAuth{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := apiKeyServer(t, lunohttp.NewAuthenticator(lunoClient, lunohttp.WithTimestampSkew(-time.Second)))
+
+	_, err = luno.NewClient(apiAuth.Key, apiAuth.Secret, luno.WithBaseURL(server.URL)).Users.Get("me")
+	if !isStatus(err, http.StatusUnauthorized) {
+		t.Errorf("expected 401 for request outside the timestamp window, got %v", err)
+	}
+}
+
+func TestRequireAPIKeyCache(t *testing.T) {
+	counter := 0
+	counting := func(next luno.Doer) luno.Doer {
+		return luno.DoerFunc(func(req *http.Request) (*http.Response, error) {
+			if luno.OperationFromContext(req.Context()) == "api_auth.get" {
+				counter++
+			}
+			return next.Do(req)
+		})
+	}
+	lunoClient, _ := lunotest.NewClient(t, luno.WithMiddleware(counting))
+	server := apiKeyServer(t, lunohttp.NewAuthenticator(lunoClient, lunohttp.WithAPIKeyCacheSize(2)))
+	call := func(key string) {
+		t.Helper()
+		_, err := luno.NewClient(key, "secret", luno.WithBaseURL(server.URL)).Users.Get("me")
+		if !isStatus(err, http.StatusUnauthorized) {
+			t.Errorf("expected 401 for key %q, got %v", key, err)
+		}
+	}
+
+	// keys which are not well formed are never looked up
+	for _, key := range []string{"../users/usr_1", "key with spaces", "key?expand=user", strings.Repeat("k", 200)} {
+		call(key)
+	}
+	if counter != 0 {
+		t.Errorf("expected malformed keys not to be looked up, got %d lookups", counter)
+	}
+
+	// unknown keys are cached, evicting the least recently used
+	call("unknown1")
+	call("unknown2")
+	call("unknown1")
+	if counter != 2 {
+		t.Errorf("expected 2 lookups, got %d", counter)
+	}
+	call("unknown3")
+	call("unknown1")
+	if counter != 3 {
+		t.Errorf("expected unknown1 to remain cached, got %d lookups", counter)
+	}
+	call("unknown2")
+	if counter != 4 {
+		t.Errorf("expected unknown2 to be evicted, got %d lookups", counter)
+	}
+}
+
+func TestRequireAPIKeyCacheCopies(t *testing.T) {
+	lunoClient, _ := lunotest.NewClient(t)
+	user, err := lunoClient.Users.Create(&luno.User{Email: "customer@example.com"}, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiAuth, err := lunoClient.APIAuth.Create(&luno.APIAuth{UserID: user.ID}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := lunohttp.NewAuthenticator(lunoClient)
+	server := httptest.NewServer(auth.RequireAPIKey(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiAuth, _ := lunohttp.APIAuthFromContext(r.Context())
+		_ = json.NewEncoder(w).Encode(apiAuth.User)
+		// a handler modifying the APIAuth does not affect later requests
+		apiAuth.Secret = "modified"
+		apiAuth.User.Email = "modified@example.com"
+	})))
+	defer server.Close()
+
+	customer := luno.NewClient(apiAuth.Key, apiAuth.Secret, luno.WithBaseURL(server.URL))
+	for i := 0; i < 2; i++ {
+		got, err := customer.Users.Get("me")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Email != user.Email {
+			t.Errorf("expected cached user %s, got %s", user.Email, got.Email)
+		}
+	}
+}
+
+// isStatus checks if err is a luno request error with the provided status
+func isStatus(err error, status int) bool {
+	var reqErr *luno.RequestError
+	return errors.As(err, &reqErr) && reqErr.StatusCode == status
+}
@@ -0,0 +1,280 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+// Package lunohttp provides net/http middleware for authenticating the users
+// of a web application with Luno sessions.
+package lunohttp
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mschoch/luno-go"
+)
+
+// DefaultCookieName is the name of the cookie holding the session key
+const DefaultCookieName = "luno_session"
+
+// DefaultHeaderName is the name of the header holding the session key, when
+// there is no session cookie
+const DefaultHeaderName = "X-Luno-Session"
+
+// ErrNoSession is returned by Authenticate when the request does not carry a
+// session key
+var ErrNoSession = errors.New("no luno session key in request")
+
+// ErrorHandler responds to a request which could not be authenticated
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+// Authenticator authenticates requests using Luno sessions, the session key
+// is read from a cookie or header and checked with Sessions.Access
+type Authenticator struct {
+	client         *luno.Client
+	cookieName     string
+	cookiePath     string
+	cookieDomain   string
+	insecureCookie bool
+	headerName     string
+	unauthorized   ErrorHandler
+	errorHandler   ErrorHandler
+	timestampSkew  time.Duration
+	apiKeyTTL      time.Duration
+	apiKeySize     int
+
+	m         sync.Mutex
+	apiKeyLRU *list.List
+	apiKeys   map[string]*list.Element
+}
+
+// Option customizes an Authenticator
+type Option func(*Authenticator)
+
+// WithCookie sets the name of the cookie holding the session key, an empty
+// name disables reading the key from a cookie
+func WithCookie(name string) Option {
+	return func(a *Authenticator) {
+		a.cookieName = name
+	}
+}
+
+// WithCookiePath sets the path of the session cookie set by LoginHandler,
+// the default is "/"
+func WithCookiePath(path string) Option {
+	return func(a *Authenticator) {
+		a.cookiePath = path
+	}
+}
+
+// WithCookieDomain sets the domain of the session cookie set by
+// LoginHandler, by default the cookie is host only
+func WithCookieDomain(domain string) Option {
+	return func(a *Authenticator) {
+		a.cookieDomain = domain
+	}
+}
+
+// WithInsecureCookie allows the session cookie to be sent over plain HTTP,
+// this should only be used for local development
+func WithInsecureCookie() Option {
+	return func(a *Authenticator) {
+		a.insecureCookie = true
+	}
+}
+
+// WithHeader sets the name of the header holding the session key, an empty
+// name disables reading the key from a header.  When the header is
+// Authorization, a "Bearer " prefix is removed from the value.
+func WithHeader(name string) Option {
+	return func(a *Authenticator) {
+		a.headerName = name
+	}
+}
+
+// WithUnauthorizedHandler sets the handler called when RequireUser rejects a
+// request without a valid session, the default responds with 401
+func WithUnauthorizedHandler(h ErrorHandler) Option {
+	return func(a *Authenticator) {
+		a.unauthorized = h
+	}
+}
+
+// WithErrorHandler sets the handler called when the session could not be
+// checked, for example because Luno is unavailable, the default responds
+// with 502
+func WithErrorHandler(h ErrorHandler) Option {
+	return func(a *Authenticator) {
+		a.errorHandler = h
+	}
+}
+
+// NewAuthenticator builds a new Authenticator using the provided client,
+// additional options may be provided to customize the Authenticator
+func NewAuthenticator(client *luno.Client, opts ...Option) *Authenticator {
+	rv := &Authenticator{
+		client:        client,
+		cookieName:    DefaultCookieName,
+		cookiePath:    "/",
+		headerName:    DefaultHeaderName,
+		unauthorized:  defaultUnauthorized,
+		errorHandler:  defaultError,
+		timestampSkew: DefaultTimestampSkew,
+		apiKeyTTL:     DefaultAPIKeyCacheTTL,
+		apiKeySize:    DefaultAPIKeyCacheSize,
+		apiKeyLRU:     list.New(),
+		apiKeys:       make(map[string]*list.Element),
+	}
+	for _, opt := range opts {
+		opt(rv)
+	}
+	return rv
+}
+
+func defaultUnauthorized(w http.ResponseWriter, r *http.Request, err error) {
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
+
+func defaultError(w http.ResponseWriter, r *http.Request, err error) {
+	http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+}
+
+// SessionKey returns the session key carried by the request, or the empty
+// string if there is none
+func (a *Authenticator) SessionKey(r *http.Request) string {
+	if a.cookieName != "" {
+		if cookie, err := r.Cookie(a.cookieName); err == nil && cookie.Value != "" {
+			return cookie.Value
+		}
+	}
+	if a.headerName != "" {
+		key := r.Header.Get(a.headerName)
+		if http.CanonicalHeaderKey(a.headerName) == "Authorization" {
+			if len(key) > 7 && strings.EqualFold(key[:7], "Bearer ") {
+				key = key[7:]
+			} else {
+				key = ""
+			}
+		}
+		return strings.TrimSpace(key)
+	}
+	return ""
+}
+
+// Authenticate checks the session key carried by the request with Luno,
+// recording the access, and returns the session with its user expanded.
+// ErrNoSession is returned if the request has no session key.
+func (a *Authenticator) Authenticate(r *http.Request) (*luno.Session, error) {
+	key := a.SessionKey(r)
+	if key == "" {
+		return nil, ErrNoSession
+	}
+	access := &luno.Session{
+		Key:       key,
+		IP:        clientIP(r),
+		UserAgent: r.UserAgent(),
+	}
+	session, err := a.client.Sessions.AccessContext(r.Context(), access, []string{"user"})
+	if err != nil {
+		return nil, err
+	}
+	if session.User != nil && session.User.IsClosed() {
+		return nil, luno.ErrUserClosed
+	}
+	return session, nil
+}
+
+// RequireUser returns middleware which only calls next for requests with a
+// valid session belonging to a user, the user and session are available
+// from the request context with UserFromContext and SessionFromContext
+func (a *Authenticator) RequireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, err := a.Authenticate(r)
+		if err == nil && session.User == nil {
+			err = ErrNoSession
+		}
+		if err != nil {
+			if unauthorized(err) {
+				a.unauthorized(w, r, err)
+			} else {
+				a.errorHandler(w, r, err)
+			}
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(withSession(r.Context(), session)))
+	})
+}
+
+// OptionalUser returns middleware which calls next for all requests, if the
+// request has a valid session, the user and session are available from the
+// request context.  Failures to check the session, other than the session
+// being invalid, are passed to the error handler.
+func (a *Authenticator) OptionalUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, err := a.Authenticate(r)
+		if err != nil {
+			if unauthorized(err) {
+				next.ServeHTTP(w, r)
+			} else {
+				a.errorHandler(w, r, err)
+			}
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(withSession(r.Context(), session)))
+	})
+}
+
+// unauthorized checks if err means the request has no valid session, as
+// opposed to the session not being checked
+func unauthorized(err error) bool {
+	return errors.Is(err, ErrNoSession) ||
+		luno.IsNotFound(err) ||
+		errors.Is(err, luno.ErrSessionExpired) ||
+		errors.Is(err, luno.ErrUserClosed) ||
+		errors.Is(err, luno.ErrInvalidParams)
+}
+
+// clientIP returns the IP address of the client making the request
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+type sessionKey struct{}
+
+func withSession(ctx context.Context, session *luno.Session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, session)
+}
+
+// SessionFromContext returns the Luno session of an authenticated request
+func SessionFromContext(ctx context.Context) (*luno.Session, bool) {
+	session, ok := ctx.Value(sessionKey{}).(*luno.Session)
+	return session, ok
+}
+
+// UserFromContext returns the Luno user of an authenticated request, either
+// the user of the session, or the user of the API key
+func UserFromContext(ctx context.Context) (*luno.User, bool) {
+	if session, ok := SessionFromContext(ctx); ok && session.User != nil {
+		return session.User, true
+	}
+	if apiAuth, ok := APIAuthFromContext(ctx); ok && apiAuth.User != nil {
+		return apiAuth.User, true
+	}
+	return nil, false
+}
@@ -0,0 +1,163 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package lunohttp_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mschoch/luno-go"
+	"github.com/mschoch/luno-go/lunohttp"
+	"github.com/mschoch/luno-go/lunotest"
+)
+
+// newSession creates a user and logs them in, returning the session key
+func newSession(t *testing.T, lunoClient *luno.Client) (*luno.User, string) {
+	user, err := lunoClient.Users.Create(&luno.User{Email: "marty@example.com", Password: "secret123"}, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, session, err := lunoClient.Users.LoginWithEmail(user.Email, "secret123", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user, session.Key
+}
+
+func userHandler(t *testing.T, wantUser bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := lunohttp.UserFromContext(r.Context())
+		if ok != wantUser {
+			t.Errorf("expected user in context %t, got %t", wantUser, ok)
+		}
+		if ok {
+			_, _ = w.Write([]byte(user.ID))
+		}
+	})
+}
+
+func TestRequireUser(t *testing.T) {
+	lunoClient, _ := lunotest.NewClient(t)
+	user, key := newSession(t, lunoClient)
+	handler := lunohttp.NewAuthenticator(lunoClient).RequireUser(userHandler(t, true))
+
+	// no session
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without session, got %d", w.Code)
+	}
+
+	// unknown session
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: lunohttp.DefaultCookieName, Value: "not-a-session"})
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 with unknown session, got %d", w.Code)
+	}
+
+	// session cookie
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: lunohttp.DefaultCookieName, Value: key})
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != user.ID {
+		t.Errorf("expected 200 for %s, got %d %s", user.ID, w.Code, w.Body.String())
+	}
+
+	// session header
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(lunohttp.DefaultHeaderName, key)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200 with session header, got %d", w.Code)
+	}
+
+	// closed user
+	err := lunoClient.Users.Deactivate(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for closed user, got %d", w.Code)
+	}
+}
+
+func TestRequireUserBearerAndCustomHandler(t *testing.T) {
+	lunoClient, _ := lunotest.NewClient(t)
+	_, key := newSession(t, lunoClient)
+	var handlerErr error
+	auth := lunohttp.NewAuthenticator(lunoClient,
+		lunohttp.WithCookie(""),
+		lunohttp.WithHeader("Authorization"),
+		lunohttp.WithUnauthorizedHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+			handlerErr = err
+			w.WriteHeader(http.StatusTeapot)
+		}))
+	handler := auth.RequireUser(userHandler(t, true))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: lunohttp.DefaultCookieName, Value: key})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusTeapot || !errors.Is(handlerErr, lunohttp.ErrNoSession) {
+		t.Errorf("expected custom handler with no session, got %d %v", w.Code, handlerErr)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+key)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200 with bearer token, got %d", w.Code)
+	}
+}
+
+func TestOptionalUser(t *testing.T) {
+	lunoClient, _ := lunotest.NewClient(t)
+	_, key := newSession(t, lunoClient)
+	auth := lunohttp.NewAuthenticator(lunoClient)
+
+	w := httptest.NewRecorder()
+	auth.OptionalUser(userHandler(t, false)).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200 without session, got %d", w.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: lunohttp.DefaultCookieName, Value: key})
+	w = httptest.NewRecorder()
+	auth.OptionalUser(userHandler(t, true)).ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200 with session, got %d", w.Code)
+	}
+}
+
+func TestLunoUnavailable(t *testing.T) {
+	lunoClient, server := lunotest.NewClient(t, luno.WithRetryPolicy(luno.RetryPolicy{MaxAttempts: 1}))
+	_, key := newSession(t, lunoClient)
+	server.Close()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: lunohttp.DefaultCookieName, Value: key})
+	w := httptest.NewRecorder()
+	lunohttp.NewAuthenticator(lunoClient).OptionalUser(userHandler(t, false)).ServeHTTP(w, req)
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("expected 502 when luno is unavailable, got %d", w.Code)
+	}
+}
@@ -0,0 +1,234 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package lunohttp
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/mschoch/luno-go"
+)
+
+// maxLoginBody is the maximum size of a login request body
+const maxLoginBody = 1 << 20
+
+// loginRequest is the body of a login request, exactly one of Email,
+// Username and Login should be provided
+type loginRequest struct {
+	Email    string `json:"email"`
+	Username string `json:"username"`
+	Login    string `json:"login"`
+	Password string `json:"password"`
+}
+
+// parseLoginRequest reads a login request from a JSON body, or from form
+// values
+func parseLoginRequest(r *http.Request) (*loginRequest, error) {
+	var rv loginRequest
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxLoginBody)).Decode(&rv)
+		if err != nil {
+			return nil, err
+		}
+		return &rv, nil
+	}
+	r.Body = http.MaxBytesReader(nil, r.Body, maxLoginBody)
+	err := r.ParseForm()
+	if err != nil {
+		return nil, err
+	}
+	rv.Email = r.PostForm.Get("email")
+	rv.Username = r.PostForm.Get("username")
+	rv.Login = r.PostForm.Get("login")
+	rv.Password = r.PostForm.Get("password")
+	return &rv, nil
+}
+
+// LoginHandler returns a handler which logs in a user with a POST of email,
+// username or login (either email or username) and password, as a JSON body
+// or a form.  The new session records the client IP and User-Agent, and its
+// key is set in the session cookie.  After a successful login, next is
+// called with the user and session in the request context, if next is nil
+// the user is written as JSON.
+func (a *Authenticator) LoginHandler(next http.Handler) http.Handler {
+	if next == nil {
+		next = http.HandlerFunc(writeUser)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !requirePost(w, r) {
+			return
+		}
+		login, err := parseLoginRequest(r)
+		if err != nil {
+			http.Error(w, "invalid login request", http.StatusBadRequest)
+			return
+		}
+		if login.Password == "" {
+			http.Error(w, "password is required", http.StatusBadRequest)
+			return
+		}
+		session := &luno.Session{
+			IP:        clientIP(r),
+			UserAgent: r.UserAgent(),
+		}
+		ctx := r.Context()
+		var user *luno.User
+		switch {
+		case login.Email != "":
+			user, session, err = a.client.Users.LoginWithEmailContext(ctx, login.Email, login.Password, nil, session)
+		case login.Username != "":
+			user, session, err = a.client.Users.LoginWithUsernameContext(ctx, login.Username, login.Password, nil, session)
+		case login.Login != "":
+			user, session, err = a.client.Users.LoginWithAnyContext(ctx, login.Login, login.Password, nil, session)
+		default:
+			http.Error(w, "email, username or login is required", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			if loginRejected(err) {
+				a.unauthorized(w, r, err)
+			} else {
+				a.errorHandler(w, r, err)
+			}
+			return
+		}
+		if session == nil {
+			a.errorHandler(w, r, errors.New("luno login: no session returned"))
+			return
+		}
+		session.User = user
+		a.setCookie(w, session)
+		next.ServeHTTP(w, r.WithContext(withSession(ctx, session)))
+	})
+}
+
+// LogoutHandler returns a handler which deletes the session of the request,
+// on a POST, and clears the session cookie.  Requests without a valid
+// session are treated as already logged out.  After logging out next is
+// called, if next is nil the response is 204 No Content.
+func (a *Authenticator) LogoutHandler(next http.Handler) http.Handler {
+	if next == nil {
+		next = http.HandlerFunc(writeNoContent)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !requirePost(w, r) {
+			return
+		}
+		session, err := a.Authenticate(r)
+		if err == nil {
+			err = a.client.Sessions.DeleteContext(r.Context(), session.ID)
+		}
+		if err != nil && !unauthorized(err) {
+			a.errorHandler(w, r, err)
+			return
+		}
+		a.clearCookie(w)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// LogoutEverywhereHandler returns a handler which deletes all the sessions
+// of the user of the request, on a POST, and clears the session cookie.
+// Requests without a valid session are passed to the unauthorized handler.
+// After logging out next is called, if next is nil the response is 204 No
+// Content.
+func (a *Authenticator) LogoutEverywhereHandler(next http.Handler) http.Handler {
+	if next == nil {
+		next = http.HandlerFunc(writeNoContent)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !requirePost(w, r) {
+			return
+		}
+		session, err := a.Authenticate(r)
+		if err == nil && session.User == nil {
+			err = ErrNoSession
+		}
+		if err == nil {
+			err = a.client.Users.DeleteSessionsContext(r.Context(), session.User.ID)
+		}
+		if err != nil {
+			if unauthorized(err) {
+				a.unauthorized(w, r, err)
+			} else {
+				a.errorHandler(w, r, err)
+			}
+			return
+		}
+		a.clearCookie(w)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// loginRejected checks if err means the login credentials were not
+// accepted, as opposed to the login not being attempted
+func loginRejected(err error) bool {
+	return errors.Is(err, luno.ErrIncorrectPassword) ||
+		errors.Is(err, luno.ErrUserNotFound) ||
+		errors.Is(err, luno.ErrUserClosed)
+}
+
+func requirePost(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return false
+	}
+	return true
+}
+
+func (a *Authenticator) setCookie(w http.ResponseWriter, session *luno.Session) {
+	if a.cookieName == "" {
+		return
+	}
+	cookie := a.cookie(session.Key)
+	if !session.Expires.IsZero() {
+		cookie.Expires = session.Expires.Time
+	}
+	http.SetCookie(w, cookie)
+}
+
+func (a *Authenticator) clearCookie(w http.ResponseWriter) {
+	if a.cookieName == "" {
+		return
+	}
+	cookie := a.cookie("")
+	cookie.MaxAge = -1
+	cookie.Expires = time.Unix(0, 0)
+	http.SetCookie(w, cookie)
+}
+
+func (a *Authenticator) cookie(value string) *http.Cookie {
+	return &http.Cookie{
+		Name:     a.cookieName,
+		Value:    value,
+		Path:     a.cookiePath,
+		Domain:   a.cookieDomain,
+		Secure:   !a.insecureCookie,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func writeUser(w http.ResponseWriter, r *http.Request) {
+	user, _ := UserFromContext(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(user)
+}
+
+func writeNoContent(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,198 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package lunohttp_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/mschoch/luno-go"
+	"github.com/mschoch/luno-go/lunohttp"
+	"github.com/mschoch/luno-go/lunotest"
+)
+
+func sessionCookie(t *testing.T, w *httptest.ResponseRecorder) *http.Cookie {
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == lunohttp.DefaultCookieName {
+			return cookie
+		}
+	}
+	t.Fatalf("expected session cookie to be set")
+	return nil
+}
+
+func TestLoginHandler(t *testing.T) {
+	lunoClient, _ := lunotest.NewClient(t)
+	user, err := lunoClient.Users.Create(&luno.User{Email: "marty@example.com", UserName: "marty", Password: "secret123"}, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := lunohttp.NewAuthenticator(lunoClient)
+	handler := auth.LoginHandler(nil)
+
+	// wrong method
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/login", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET, got %d", w.Code)
+	}
+
+	// wrong password
+	form := url.Values{"email": {user.Email}, "password": {"wrong"}}
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for wrong password, got %d", w.Code)
+	}
+
+	// form login with username
+	form = url.Values{"username": {"marty"}, "password": {"secret123"}}
+	req = httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "lunohttp-test")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for login, got %d %s", w.Code, w.Body.String())
+	}
+	var loggedIn luno.User
+	err = json.Unmarshal(w.Body.Bytes(), &loggedIn)
+	if err != nil || loggedIn.ID != user.ID {
+		t.Errorf("expected user %s in response, got %s %v", user.ID, w.Body.String(), err)
+	}
+	cookie := sessionCookie(t, w)
+	if !cookie.Secure || !cookie.HttpOnly || cookie.Value == "" {
+		t.Errorf("expected secure http only session cookie, got %v", cookie)
+	}
+	sessions, err := lunoClient.Sessions.Recent(nil, &luno.SessionFilter{UserID: user.ID}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions.List) != 1 || sessions.List[0].UserAgent != "lunohttp-test" || sessions.List[0].IP == "" {
+		t.Errorf("expected session with client ip and user agent, got %+v", sessions.List)
+	}
+
+	// json login with any
+	req = httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"login":"marty@example.com","password":"secret123"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200 for json login, got %d %s", w.Code, w.Body.String())
+	}
+
+	// missing login
+	req = httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"password":"secret123"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without login, got %d", w.Code)
+	}
+}
+
+func TestLoginHandlerNoSession(t *testing.T) {
+	noSession := func(next luno.Doer) luno.Doer {
+		return luno.DoerFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       io.NopCloser(strings.NewReader(`{"user":{"id":"usr_1","type":"user"}}`)),
+				Request:    req,
+			}, nil
+		})
+	}
+	lunoClient, _ := lunotest.NewClient(t, luno.WithMiddleware(noSession))
+	var handled error
+	handler := lunohttp.NewAuthenticator(lunoClient, lunohttp.WithErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+		handled = err
+		w.WriteHeader(http.StatusBadGateway)
+	})).LoginHandler(nil)
+
+	form := url.Values{"email": {"marty@example.com"}, "password": {"secret123"}}
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusBadGateway || handled == nil || !strings.Contains(handled.Error(), "no session") {
+		t.Errorf("expected login without a session to be an error, got %d %v", w.Code, handled)
+	}
+}
+
+func TestLogoutHandlers(t *testing.T) {
+	lunoClient, _ := lunotest.NewClient(t)
+	user, key := newSession(t, lunoClient)
+	_, _, err := lunoClient.Users.LoginWithEmail(user.Email, "secret123", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, other, err := lunoClient.Users.LoginWithEmail(user.Email, "secret123", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := lunohttp.NewAuthenticator(lunoClient)
+
+	// logout deletes only this session
+	req := httptest.NewRequest(http.MethodPost, "/logout", nil)
+	req.AddCookie(&http.Cookie{Name: lunohttp.DefaultCookieName, Value: key})
+	w := httptest.NewRecorder()
+	auth.LogoutHandler(nil).ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected 204 for logout, got %d", w.Code)
+	}
+	if cookie := sessionCookie(t, w); cookie.MaxAge >= 0 {
+		t.Errorf("expected session cookie to be cleared, got %v", cookie)
+	}
+	sessions, err := lunoClient.Sessions.Recent(nil, &luno.SessionFilter{UserID: user.ID}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions.List) != 2 {
+		t.Errorf("expected 2 sessions after logout, got %d", len(sessions.List))
+	}
+
+	// logging out again is fine
+	w = httptest.NewRecorder()
+	auth.LogoutHandler(nil).ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected 204 for repeated logout, got %d", w.Code)
+	}
+
+	// logout everywhere needs a valid session
+	w = httptest.NewRecorder()
+	auth.LogoutEverywhereHandler(nil).ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for logout everywhere without session, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/logout-everywhere", nil)
+	req.AddCookie(&http.Cookie{Name: lunohttp.DefaultCookieName, Value: other.Key})
+	w = httptest.NewRecorder()
+	auth.LogoutEverywhereHandler(nil).ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected 204 for logout everywhere, got %d", w.Code)
+	}
+	sessions, err = lunoClient.Sessions.Recent(nil, &luno.SessionFilter{UserID: user.ID}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions.List) != 0 {
+		t.Errorf("expected no sessions after logout everywhere, got %d", len(sessions.List))
+	}
+}
@@ -0,0 +1,563 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+// Package lunospool provides a durable on-disk spool of Luno events, so
+// that events are not lost while Luno is unreachable.
+//
+// Events are appended to segment files, and synced to disk, before they
+// are sent.  Each event is given an idempotency id, stored in its Details
+// under IDKey.  Sent events are acknowledged in a companion file of the
+// segment, and segments with every event acknowledged are removed.  When
+// the spool is reopened, unacknowledged events are sent again, skipping any
+// which Luno already recorded before a crash.
+package lunospool
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mschoch/luno-go"
+)
+
+// IDKey is the key in the Details of spooled events holding the idempotency
+// id
+const IDKey = "spool_id"
+
+// DefaultSegmentSize is the size at which a new segment file is started
+const DefaultSegmentSize = 4 << 20
+
+const (
+	segmentExt = ".log"
+	ackExt     = ".ack"
+
+	// markers in the ack file
+	markTried = "T"
+	markAcked = "A"
+)
+
+// ErrorHandler is called with events which Luno rejected, such events are
+// acknowledged so they do not block the spool.  Errors which would reject
+// every event, such as invalid credentials or a wrong base URL, are
+// returned from Flush instead and the events are kept.
+type ErrorHandler func(event *luno.Event, err error)
+
+// Option customizes a Spool
+type Option func(*Spool)
+
+// WithSegmentSize sets the size at which a new segment file is started
+func WithSegmentSize(size int64) Option {
+	return func(s *Spool) {
+		s.segmentSize = size
+	}
+}
+
+// WithErrorHandler sets the handler called with events rejected by Luno
+func WithErrorHandler(h ErrorHandler) Option {
+	return func(s *Spool) {
+		s.onError = h
+	}
+}
+
+// Spool is a durable queue of events to be sent to Luno, it is safe for
+// concurrent use, but a directory must only be used by one Spool at a time
+type Spool struct {
+	dir         string
+	client      *luno.Client
+	segmentSize int64
+	onError     ErrorHandler
+	notify      chan struct{}
+
+	flushM sync.Mutex // serializes Flush
+
+	m        sync.Mutex
+	segments []*segment // oldest first, the last is active
+	ids      map[string]*record
+	closed   bool
+}
+
+type segment struct {
+	seq     uint64
+	log     *os.File // only open for the active segment
+	ack     *os.File
+	size    int64
+	records []*record
+	acked   int
+}
+
+type record struct {
+	ID    string      `json:"id"`
+	Event *luno.Event `json:"event"`
+
+	seg   *segment
+	tried bool
+	acked bool
+}
+
+// Open opens the spool in dir, creating the directory if needed, and loads
+// any events not yet acknowledged.  Events are sent with the provided
+// client.
+func Open(dir string, client *luno.Client, opts ...Option) (*Spool, error) {
+	rv := &Spool{
+		dir:         dir,
+		client:      client,
+		segmentSize: DefaultSegmentSize,
+		notify:      make(chan struct{}, 1),
+		ids:         make(map[string]*record),
+	}
+	for _, opt := range opts {
+		opt(rv)
+	}
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, fmt.Errorf("error creating spool dir: %v", err)
+	}
+	seqs, err := rv.listSegments()
+	if err != nil {
+		return nil, err
+	}
+	var next uint64 = 1
+	for _, seq := range seqs {
+		seg, err := rv.loadSegment(seq)
+		if err != nil {
+			rv.closeFiles()
+			return nil, err
+		}
+		rv.segments = append(rv.segments, seg)
+		next = seq + 1
+	}
+	rv.compact()
+	// always append to a new segment, leaving loaded segments to be
+	// removed once acknowledged
+	err = rv.startSegment(next)
+	if err != nil {
+		rv.closeFiles()
+		return nil, err
+	}
+	return rv, nil
+}
+
+func (s *Spool) segmentPath(seq uint64, ext string) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", seq, ext))
+}
+
+// listSegments returns the sequence numbers of the segment files, in order
+func (s *Spool) listSegments() ([]uint64, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading spool dir: %v", err)
+	}
+	var rv []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		rv = append(rv, seq)
+	}
+	sort.Slice(rv, func(i, j int) bool { return rv[i] < rv[j] })
+	return rv, nil
+}
+
+// loadSegment reads the events and acknowledgements of a segment, an
+// incomplete final line, from a write interrupted by a crash, is ignored
+func (s *Spool) loadSegment(seq uint64) (*segment, error) {
+	seg := &segment{seq: seq}
+	err := readLines(s.segmentPath(seq, segmentExt), func(line []byte) {
+		var rec record
+		if json.Unmarshal(line, &rec) != nil || rec.ID == "" || rec.Event == nil {
+			return
+		}
+		rec.seg = seg
+		seg.records = append(seg.records, &rec)
+		s.ids[rec.ID] = &rec
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading spool segment: %v", err)
+	}
+	err = readLines(s.segmentPath(seq, ackExt), func(line []byte) {
+		mark, id, ok := strings.Cut(string(line), " ")
+		rec := s.ids[id]
+		if !ok || rec == nil || rec.seg != seg {
+			return
+		}
+		switch mark {
+		case markTried:
+			rec.tried = true
+		case markAcked:
+			if !rec.acked {
+				rec.acked = true
+				seg.acked++
+			}
+		}
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("error reading spool acks: %v", err)
+	}
+	seg.ack, err = os.OpenFile(s.segmentPath(seq, ackExt), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening spool acks: %v", err)
+	}
+	return seg, nil
+}
+
+func readLines(path string, f func(line []byte)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 64<<20)
+	for scanner.Scan() {
+		f(scanner.Bytes())
+	}
+	return scanner.Err()
+}
+
+func (s *Spool) startSegment(seq uint64) error {
+	seg := &segment{seq: seq}
+	var err error
+	seg.log, err = os.OpenFile(s.segmentPath(seq, segmentExt), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("error creating spool segment: %v", err)
+	}
+	seg.ack, err = os.OpenFile(s.segmentPath(seq, ackExt), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		_ = seg.log.Close()
+		return fmt.Errorf("error creating spool acks: %v", err)
+	}
+	s.segments = append(s.segments, seg)
+	return nil
+}
+
+func (s *Spool) active() *segment {
+	return s.segments[len(s.segments)-1]
+}
+
+// Append durably adds an event to the spool, returning its idempotency id.
+// An event without a Timestamp is given the current time, so that it is
+// recorded with when it happened rather than when it is sent.  If the
+// Details of the event already hold an id, which is still in the spool,
+// the event is not added again.
+func (s *Spool) Append(event *luno.Event) (string, error) {
+	details, err := luno.EncodeDetails(event.Details)
+	if err != nil {
+		return "", err
+	}
+	if details == nil {
+		details = make(map[string]interface{})
+	}
+	id, _ := details[IDKey].(string)
+	if id == "" {
+		id, err = newID()
+		if err != nil {
+			return "", err
+		}
+		details[IDKey] = id
+	}
+	spooled := *event
+	spooled.Details = details
+	if spooled.Timestamp.IsZero() {
+		spooled.Timestamp = luno.NewTime(time.Now())
+	}
+	rec := &record{ID: id, Event: &spooled}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling spool event json: %v", err)
+	}
+	line = append(line, '\n')
+
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.closed {
+		return "", fmt.Errorf("spool is closed")
+	}
+	if _, ok := s.ids[id]; ok {
+		return id, nil
+	}
+	seg := s.active()
+	if seg.size > 0 && seg.size+int64(len(line)) > s.segmentSize {
+		err = s.rotate()
+		if err != nil {
+			return "", err
+		}
+		seg = s.active()
+	}
+	_, err = seg.log.Write(line)
+	if err == nil {
+		err = seg.log.Sync()
+	}
+	if err != nil {
+		return "", fmt.Errorf("error writing spool segment: %v", err)
+	}
+	seg.size += int64(len(line))
+	rec.seg = seg
+	seg.records = append(seg.records, rec)
+	s.ids[id] = rec
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	return id, nil
+}
+
+// rotate closes the log of the active segment and starts a new one
+func (s *Spool) rotate() error {
+	seg := s.active()
+	err := seg.log.Close()
+	seg.log = nil
+	if err != nil {
+		return fmt.Errorf("error closing spool segment: %v", err)
+	}
+	return s.startSegment(seg.seq + 1)
+}
+
+// Pending returns the number of events not yet acknowledged
+func (s *Spool) Pending() int {
+	s.m.Lock()
+	defer s.m.Unlock()
+	rv := 0
+	for _, seg := range s.segments {
+		rv += len(seg.records) - seg.acked
+	}
+	return rv
+}
+
+// Flush sends the events not yet acknowledged, in the order they were
+// appended.  It stops at the first event which fails with an error other
+// than Luno rejecting the event, leaving it and later events for the next
+// Flush.
+func (s *Spool) Flush(ctx context.Context) error {
+	s.flushM.Lock()
+	defer s.flushM.Unlock()
+
+	s.m.Lock()
+	var pending []*record
+	for _, seg := range s.segments {
+		for _, rec := range seg.records {
+			if !rec.acked {
+				pending = append(pending, rec)
+			}
+		}
+	}
+	s.m.Unlock()
+
+	for _, rec := range pending {
+		err := s.send(ctx, rec)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+	if seg := s.active(); seg.size > 0 && seg.acked == len(seg.records) && !s.closed {
+		err := s.rotate()
+		if err != nil {
+			return err
+		}
+	}
+	s.compact()
+	return nil
+}
+
+// send sends a single event and acknowledges it.  An event which may
+// already have been sent is first looked for in Luno.
+func (s *Spool) send(ctx context.Context, rec *record) error {
+	if rec.tried {
+		found, err := s.recorded(ctx, rec)
+		if err != nil {
+			return err
+		}
+		if found {
+			return s.mark(rec, markAcked)
+		}
+	} else {
+		err := s.mark(rec, markTried)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := s.client.Events.CreateContext(ctx, rec.Event, nil)
+	if err != nil {
+		if ctx.Err() != nil || !rejected(err) {
+			return err
+		}
+		if s.onError != nil {
+			s.onError(rec.Event, err)
+		}
+	}
+	return s.mark(rec, markAcked)
+}
+
+// rejected checks if err is Luno rejecting the event itself.  Errors which
+// are the fault of the client or its environment, such as authentication
+// failures, not found from a misconfigured base URL, or responses which
+// are not Luno errors, as from a proxy, are not rejections.
+func rejected(err error) bool {
+	var lerr *luno.Error
+	if !errors.As(err, &lerr) || luno.IsAuth(err) || luno.IsRetryable(err) {
+		return false
+	}
+	return lerr.Status >= 400 && lerr.Status < 500 && lerr.Status != http.StatusNotFound
+}
+
+// recorded checks if Luno has an event with the id of rec, paging through
+// the recent events with the same name and user until those older than rec
+func (s *Spool) recorded(ctx context.Context, rec *record) (bool, error) {
+	filter := &luno.EventFilter{Name: rec.Event.Name, UserID: rec.Event.UserID}
+	events := s.client.Events.Iter(ctx, nil, filter, nil)
+	for events.Next() {
+		event := events.Value()
+		details, ok := event.Details.(map[string]interface{})
+		if ok && details[IDKey] == rec.ID {
+			return true, nil
+		}
+		if !rec.Event.Timestamp.IsZero() && event.Timestamp.Before(rec.Event.Timestamp.Time) {
+			break
+		}
+	}
+	return false, events.Err()
+}
+
+// mark durably records that an event was tried or acknowledged
+func (s *Spool) mark(rec *record, mark string) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.closed {
+		return fmt.Errorf("spool is closed")
+	}
+	_, err := rec.seg.ack.WriteString(mark + " " + rec.ID + "\n")
+	if err == nil {
+		err = rec.seg.ack.Sync()
+	}
+	if err != nil {
+		return fmt.Errorf("error writing spool acks: %v", err)
+	}
+	switch mark {
+	case markTried:
+		rec.tried = true
+	case markAcked:
+		if !rec.acked {
+			rec.acked = true
+			rec.seg.acked++
+		}
+	}
+	return nil
+}
+
+// compact removes the segments, other than the active one, with every
+// event acknowledged
+func (s *Spool) compact() {
+	kept := s.segments[:0]
+	for i, seg := range s.segments {
+		if i == len(s.segments)-1 || seg.log != nil || seg.acked < len(seg.records) {
+			kept = append(kept, seg)
+			continue
+		}
+		_ = seg.ack.Close()
+		if os.Remove(s.segmentPath(seg.seq, segmentExt)) != nil {
+			// try again next time
+			kept = append(kept, seg)
+			continue
+		}
+		_ = os.Remove(s.segmentPath(seg.seq, ackExt))
+		for _, rec := range seg.records {
+			delete(s.ids, rec.ID)
+		}
+	}
+	s.segments = kept
+}
+
+// Run flushes the spool whenever events are appended, and every interval,
+// until the context is done.  Errors from Flush are passed to onError, if
+// it is not nil, and the flush is tried again after the next interval.
+func (s *Spool) Run(ctx context.Context, interval time.Duration, onError func(error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		err := s.Flush(ctx)
+		if err != nil && ctx.Err() == nil && onError != nil {
+			onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		case <-s.notify:
+			if err != nil {
+				// don't hammer luno while it is failing
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-ticker.C:
+				}
+			}
+		}
+	}
+}
+
+// Close closes the files of the spool, after waiting for a Flush in
+// progress.  Events not yet acknowledged remain on disk to be sent when the
+// spool is next opened.
+func (s *Spool) Close() error {
+	s.flushM.Lock()
+	defer s.flushM.Unlock()
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.closeFiles()
+}
+
+func (s *Spool) closeFiles() error {
+	var rv error
+	for _, seg := range s.segments {
+		if seg.log != nil {
+			if err := seg.log.Close(); err != nil && rv == nil {
+				rv = err
+			}
+		}
+		if err := seg.ack.Close(); err != nil && rv == nil {
+			rv = err
+		}
+	}
+	return rv
+}
+
+func newID() (string, error) {
+	buf := make([]byte, 16)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", fmt.Errorf("error generating spool id: %v", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,352 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package lunospool_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mschoch/luno-go"
+	"github.com/mschoch/luno-go/lunospool"
+	"github.com/mschoch/luno-go/lunotest"
+)
+
+// unavailable fails every request as if Luno were down
+func unavailable(next luno.Doer) luno.Doer {
+	return luno.DoerFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusServiceUnavailable,
+			Header:     make(http.Header),
+			Body:       io.NopCloser(strings.NewReader(`{"code":"service_unavailable"}`)),
+			Request:    req,
+		}, nil
+	})
+}
+
+// lostResponse sends requests, but loses the response of event creation, as
+// if the connection dropped after Luno recorded the event
+func lostResponse(next luno.Doer) luno.Doer {
+	return luno.DoerFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := next.Do(req)
+		if err == nil && luno.OperationFromContext(req.Context()) == "events.create" {
+			_ = resp.Body.Close()
+			return unavailable(next).Do(req)
+		}
+		return resp, err
+	})
+}
+
+func segmentFiles(t *testing.T, dir string) []string {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return matches
+}
+
+func countEvents(t *testing.T, lunoClient *luno.Client) int {
+	events, err := lunoClient.Events.Recent(nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(events.List)
+}
+
+func TestSpoolReplayAfterOutage(t *testing.T) {
+	dir := t.TempDir()
+	server := lunotest.NewServer()
+	defer server.Close()
+	retryOnce := luno.WithRetryPolicy(luno.RetryPolicy{MaxAttempts: 1})
+
+	spool, err := lunospool.Open(dir, server.Client(retryOnce, luno.WithMiddleware(unavailable)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		_, err = spool.Append(&luno.Event{Name: "Audit", Details: map[string]interface{}{"n": i}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = spool.Flush(context.Background())
+	if !luno.IsRetryable(err) {
+		t.Errorf("expected retryable error while luno is down, got %v", err)
+	}
+	if spool.Pending() != 3 {
+		t.Errorf("expected 3 pending events, got %d", spool.Pending())
+	}
+	err = spool.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// reopen, as after a restart, with luno available
+	lunoClient := server.Client(retryOnce)
+	spool, err = lunospool.Open(dir, lunoClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = spool.Close() }()
+	if spool.Pending() != 3 {
+		t.Errorf("expected 3 pending events after reopen, got %d", spool.Pending())
+	}
+	err = spool.Flush(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spool.Pending() != 0 {
+		t.Errorf("expected no pending events, got %d", spool.Pending())
+	}
+	if n := countEvents(t, lunoClient); n != 3 {
+		t.Errorf("expected 3 events in luno, got %d", n)
+	}
+	if files := segmentFiles(t, dir); len(files) != 1 {
+		t.Errorf("expected acknowledged segments to be compacted, got %v", files)
+	}
+}
+
+func TestSpoolDedupe(t *testing.T) {
+	dir := t.TempDir()
+	server := lunotest.NewServer()
+	defer server.Close()
+	retryOnce := luno.WithRetryPolicy(luno.RetryPolicy{MaxAttempts: 1})
+
+	spool, err := lunospool.Open(dir, server.Client(retryOnce, luno.WithMiddleware(lostResponse)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := &luno.Event{Name: "Audit"}
+	id, err := spool.Append(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Details != nil {
+		t.Errorf("expected callers event not to be modified")
+	}
+	err = spool.Flush(context.Background())
+	if err == nil {
+		t.Fatalf("expected flush to fail")
+	}
+	// appending the same id again is ignored
+	_, err = spool.Append(&luno.Event{Name: "Audit", Details: map[string]interface{}{lunospool.IDKey: id}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spool.Pending() != 1 {
+		t.Errorf("expected 1 pending event, got %d", spool.Pending())
+	}
+	err = spool.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lunoClient := server.Client(retryOnce)
+	spool, err = lunospool.Open(dir, lunoClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = spool.Close() }()
+	err = spool.Flush(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spool.Pending() != 0 {
+		t.Errorf("expected no pending events, got %d", spool.Pending())
+	}
+	if n := countEvents(t, lunoClient); n != 1 {
+		t.Errorf("expected the event to be recorded once, got %d", n)
+	}
+}
+
+func TestSpoolDedupeBeyondFirstPage(t *testing.T) {
+	dir := t.TempDir()
+	server := lunotest.NewServer()
+	defer server.Close()
+	retryOnce := luno.WithRetryPolicy(luno.RetryPolicy{MaxAttempts: 1})
+
+	spool, err := lunospool.Open(dir, server.Client(retryOnce, luno.WithMiddleware(lostResponse)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := spool.Append(&luno.Event{Name: "Audit"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = spool.Flush(context.Background())
+	if err == nil {
+		t.Fatalf("expected flush to fail")
+	}
+	err = spool.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// newer events push the recorded event off the first page
+	lunoClient := server.Client(retryOnce)
+	for i := 0; i < lunotest.DefaultLimit+10; i++ {
+		_, err = lunoClient.Events.Create(&luno.Event{Name: "Audit"}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	spool, err = lunospool.Open(dir, lunoClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = spool.Close() }()
+	err = spool.Flush(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for event, err := range lunoClient.Events.All(context.Background(), nil, nil, nil) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if details, ok := event.Details.(map[string]interface{}); ok && details[lunospool.IDKey] == id {
+			n++
+		}
+	}
+	if n != 1 {
+		t.Errorf("expected the event to be recorded once, got %d", n)
+	}
+}
+
+func TestSpoolTornWriteAndRotation(t *testing.T) {
+	dir := t.TempDir()
+	lunoClient, _ := lunotest.NewClient(t)
+
+	spool, err := lunospool.Open(dir, lunoClient, lunospool.WithSegmentSize(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		_, err = spool.Append(&luno.Event{Name: "Audit"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if files := segmentFiles(t, dir); len(files) != 4 {
+		t.Errorf("expected a segment per event, got %v", files)
+	}
+	err = spool.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// simulate a crash part way through writing an event
+	files := segmentFiles(t, dir)
+	f, err := os.OpenFile(files[len(files)-1], os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.WriteString(`{"id":"torn","event":{"na`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	spool, err = lunospool.Open(dir, lunoClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = spool.Close() }()
+	if spool.Pending() != 4 {
+		t.Errorf("expected 4 pending events, got %d", spool.Pending())
+	}
+	err = spool.Flush(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := countEvents(t, lunoClient); n != 4 {
+		t.Errorf("expected 4 events in luno, got %d", n)
+	}
+	if files := segmentFiles(t, dir); len(files) != 1 {
+		t.Errorf("expected acknowledged segments to be compacted, got %v", files)
+	}
+}
+
+// responding fails every request with the provided status and body
+func responding(status int, body string) func(luno.Doer) luno.Doer {
+	return func(next luno.Doer) luno.Doer {
+		return luno.DoerFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: status,
+				Header:     make(http.Header),
+				Body:       io.NopCloser(strings.NewReader(body)),
+				Request:    req,
+			}, nil
+		})
+	}
+}
+
+func TestSpoolRejectedEvent(t *testing.T) {
+	lunoClient, _ := lunotest.NewClient(t, luno.WithMiddleware(responding(http.StatusBadRequest, `{"code":"invalid_params"}`)))
+	var rejected []error
+	spool, err := lunospool.Open(t.TempDir(), lunoClient, lunospool.WithErrorHandler(func(event *luno.Event, err error) {
+		rejected = append(rejected, err)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = spool.Close() }()
+	_, err = spool.Append(&luno.Event{Name: "Audit"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = spool.Flush(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spool.Pending() != 0 || len(rejected) != 1 || !luno.IsErrorCode(rejected[0], luno.ErrCodeInvalidParams) {
+		t.Errorf("expected rejected event to be reported and acknowledged, got %d pending, %v", spool.Pending(), rejected)
+	}
+}
+
+func TestSpoolKeepsEventsOnClientErrors(t *testing.T) {
+	tests := map[string]func(luno.Doer) luno.Doer{
+		"invalid api key":   responding(http.StatusUnauthorized, `{"code":"invalid_api_key"}`),
+		"invalid timestamp": responding(http.StatusBadRequest, `{"code":"invalid_timestamp"}`),
+		"not found":         responding(http.StatusNotFound, `{"code":"not_found"}`),
+		"not a luno error":  responding(http.StatusBadRequest, `<html>Bad Request</html>`),
+	}
+	for name, middleware := range tests {
+		t.Run(name, func(t *testing.T) {
+			lunoClient, _ := lunotest.NewClient(t, luno.WithMiddleware(middleware))
+			var rejected []error
+			spool, err := lunospool.Open(t.TempDir(), lunoClient, lunospool.WithErrorHandler(func(event *luno.Event, err error) {
+				rejected = append(rejected, err)
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = spool.Close() }()
+			_, err = spool.Append(&luno.Event{Name: "Audit"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = spool.Flush(context.Background())
+			if err == nil {
+				t.Errorf("expected flush to fail")
+			}
+			if spool.Pending() != 1 || len(rejected) != 0 {
+				t.Errorf("expected event to be kept, got %d pending, %v", spool.Pending(), rejected)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package lunotest
+
+import (
+	"net/http"
+
+	"github.com/mschoch/luno-go"
+)
+
+func (s *Server) handleAccount(req *request) (interface{}, int, *luno.Error) {
+	if len(req.parts) != 0 {
+		return nil, 0, errNotFound()
+	}
+	switch req.method() {
+	case http.MethodGet:
+		rv := *s.account
+		return &rv, http.StatusOK, nil
+	case http.MethodPut:
+		var update luno.Account
+		if err := req.decode(&update); err != nil {
+			return nil, 0, err
+		}
+		if update.Email != "" {
+			s.account.Email = update.Email
+		}
+		if update.Name != "" {
+			s.account.Name = update.Name
+		}
+		if update.FirstName != "" {
+			s.account.FirstName = update.FirstName
+		}
+		if update.LastName != "" {
+			s.account.LastName = update.LastName
+		}
+		if req.boolParam("auto_name") {
+			s.account.FirstName, s.account.LastName = splitName(s.account.Name)
+		}
+		rv := *s.account
+		return &rv, http.StatusOK, nil
+	}
+	return nil, 0, errMethodNotAllowed()
+}
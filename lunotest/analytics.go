@@ -0,0 +1,203 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package lunotest
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mschoch/luno-go"
+)
+
+// dateFormat is the format of dates in analytics parameters
+const dateFormat = "2006-01-02"
+
+func (s *Server) handleAnalytics(req *request) (interface{}, int, *luno.Error) {
+	if req.method() != http.MethodGet {
+		return nil, 0, errMethodNotAllowed()
+	}
+	var times []time.Time
+	switch strings.Join(req.parts, "/") {
+	case "users":
+		for _, rec := range s.users {
+			times = append(times, rec.user.Created.Time)
+		}
+	case "sessions":
+		for _, session := range s.sessions {
+			times = append(times, session.Created.Time)
+		}
+	case "events":
+		for _, event := range s.events {
+			times = append(times, event.Timestamp.Time)
+		}
+	case "events/list":
+		return s.eventsList(), http.StatusOK, nil
+	case "events/timeline":
+		return s.eventsTimeline(req)
+	default:
+		return nil, 0, errNotFound()
+	}
+	return s.aggregate(req, times)
+}
+
+// aggregate counts the times, in total and within the number of days
+// requested by the days parameter
+func (s *Server) aggregate(req *request, times []time.Time) (interface{}, int, *luno.Error) {
+	days := req.params["days"]
+	rv := luno.EntityAggregate{}
+	if len(days) == 0 {
+		days = []string{"7", "28"}
+		rv["total"] = len(times)
+	}
+	now := s.Now()
+	for _, day := range days {
+		n, err := strconv.Atoi(day)
+		if err != nil || n < 1 {
+			return nil, 0, errInvalidParams("days must be positive integers")
+		}
+		since := now.Add(-time.Duration(n) * 24 * time.Hour)
+		count := 0
+		for _, t := range times {
+			if t.After(since) {
+				count++
+			}
+		}
+		rv[day+"_days"] = count
+	}
+	return rv, http.StatusOK, nil
+}
+
+func (s *Server) eventsList() *luno.EventAggregates {
+	byName := make(map[string]*luno.EventAggregate)
+	for _, event := range s.events {
+		agg, ok := byName[event.Name]
+		if !ok {
+			agg = &luno.EventAggregate{
+				Entity: luno.Entity{Type: "event_aggregate"},
+				Name:   event.Name,
+			}
+			byName[event.Name] = agg
+		}
+		agg.Count++
+		if event.Timestamp.After(agg.Last.Time) {
+			agg.Last = event.Timestamp
+		}
+	}
+	rv := &luno.EventAggregates{List: []*luno.EventAggregate{}}
+	for _, agg := range byName {
+		rv.List = append(rv.List, agg)
+	}
+	sort.Slice(rv.List, func(i, j int) bool {
+		return rv.List[i].Name < rv.List[j].Name
+	})
+	return rv
+}
+
+// eventsTimeline counts events matching the filter in buckets of the
+// requested group, which is one of hour, day (the default) or month
+func (s *Server) eventsTimeline(req *request) (interface{}, int, *luno.Error) {
+	name := req.params.Get("name")
+	userID := req.params.Get("user_id")
+	distinct := req.boolParam("distinct")
+	group := req.params.Get("group")
+	if group == "" {
+		group = "day"
+	}
+	var bucket func(time.Time) (time.Time, time.Time)
+	switch group {
+	case "hour":
+		bucket = func(t time.Time) (time.Time, time.Time) {
+			start := t.Truncate(time.Hour)
+			return start, start.Add(time.Hour)
+		}
+	case "day":
+		bucket = func(t time.Time) (time.Time, time.Time) {
+			start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+			return start, start.AddDate(0, 0, 1)
+		}
+	case "month":
+		bucket = func(t time.Time) (time.Time, time.Time) {
+			start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+			return start, start.AddDate(0, 1, 0)
+		}
+	default:
+		return nil, 0, errInvalidParams("group must be one of hour, day or month")
+	}
+	var from, to time.Time
+	var err error
+	if param := req.params.Get("from"); param != "" {
+		from, err = time.Parse(dateFormat, param)
+		if err != nil {
+			return nil, 0, errInvalidParams("from must be a date")
+		}
+	}
+	if param := req.params.Get("to"); param != "" {
+		to, err = time.Parse(dateFormat, param)
+		if err != nil {
+			return nil, 0, errInvalidParams("to must be a date")
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+
+	type counter struct {
+		entry *luno.TimelineEntry
+		users map[string]bool
+	}
+	buckets := make(map[time.Time]*counter)
+	totalUsers := make(map[string]bool)
+	rv := &luno.EventsTimeline{Timeline: []*luno.TimelineEntry{}}
+	for _, event := range s.events {
+		if (name != "" && event.Name != name) || (userID != "" && event.UserID != userID) {
+			continue
+		}
+		when := event.Timestamp.UTC()
+		if (!from.IsZero() && when.Before(from)) || (!to.IsZero() && !when.Before(to)) {
+			continue
+		}
+		start, end := bucket(when)
+		c, ok := buckets[start]
+		if !ok {
+			c = &counter{
+				entry: &luno.TimelineEntry{
+					Timestamp: luno.NewTime(start),
+					Range: &luno.Range{
+						From: start.Format(time.RFC3339),
+						To:   end.Format(time.RFC3339),
+					},
+				},
+				users: make(map[string]bool),
+			}
+			buckets[start] = c
+			rv.Timeline = append(rv.Timeline, c.entry)
+		}
+		if distinct {
+			if !c.users[event.UserID] {
+				c.users[event.UserID] = true
+				c.entry.Count++
+			}
+			if !totalUsers[event.UserID] {
+				totalUsers[event.UserID] = true
+				rv.Total++
+			}
+		} else {
+			c.entry.Count++
+			rv.Total++
+		}
+	}
+	sort.Slice(rv.Timeline, func(i, j int) bool {
+		return rv.Timeline[i].Timestamp.Before(rv.Timeline[j].Timestamp.Time)
+	})
+	return rv, http.StatusOK, nil
+}
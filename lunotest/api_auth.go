@@ -0,0 +1,118 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package lunotest
+
+import (
+	"net/http"
+
+	"github.com/mschoch/luno-go"
+)
+
+// findAPIAuth finds api authentication by key or id, returning the index
+// in s.apiAuths
+func (s *Server) findAPIAuth(key string) (int, *luno.APIAuth) {
+	for i, apiAuth := range s.apiAuths {
+		if apiAuth.Key == key || apiAuth.ID == key {
+			return i, apiAuth
+		}
+	}
+	return -1, nil
+}
+
+// apiAuthView returns a copy of the api authentication, with the user
+// expanded if requested
+func (s *Server) apiAuthView(apiAuth *luno.APIAuth, expandUser bool) *luno.APIAuth {
+	rv := *apiAuth
+	rv.User = nil
+	if expandUser && apiAuth.UserID != "" {
+		if _, rec := s.findUser(apiAuth.UserID); rec != nil {
+			rv.User = userCopy(rec.user)
+		}
+	}
+	return &rv
+}
+
+func (s *Server) handleAPIAuths(req *request) (interface{}, int, *luno.Error) {
+	switch {
+	case len(req.parts) == 0 && req.method() == http.MethodGet:
+		return s.recentAPIAuths(req)
+	case len(req.parts) == 0 && req.method() == http.MethodPost:
+		return s.createAPIAuth(req)
+	case len(req.parts) != 1:
+		return nil, 0, errMethodNotAllowed()
+	}
+
+	i, apiAuth := s.findAPIAuth(req.parts[0])
+	if apiAuth == nil {
+		return nil, 0, &luno.Error{Code: luno.ErrCodeAPIAuthNotFound, Message: "API authentication not found", Status: http.StatusNotFound}
+	}
+	switch req.method() {
+	case http.MethodGet:
+		return s.apiAuthView(apiAuth, req.expand("user")), http.StatusOK, nil
+	case http.MethodPut, http.MethodPatch:
+		var update luno.APIAuth
+		if err := req.decode(&update); err != nil {
+			return nil, 0, err
+		}
+		if req.method() == http.MethodPut {
+			apiAuth.Details = update.Details
+		} else if update.Details != nil {
+			apiAuth.Details = mergeDetails(apiAuth.Details, update.Details)
+		}
+		return s.apiAuthView(apiAuth, req.expand("user")), http.StatusOK, nil
+	case http.MethodDelete:
+		s.apiAuths = append(s.apiAuths[:i], s.apiAuths[i+1:]...)
+		return success, http.StatusOK, nil
+	}
+	return nil, 0, errMethodNotAllowed()
+}
+
+func (s *Server) recentAPIAuths(req *request) (interface{}, int, *luno.Error) {
+	userID := req.params.Get("user_id")
+	var ids []string
+	for i := len(s.apiAuths) - 1; i >= 0; i-- {
+		if userID == "" || s.apiAuths[i].UserID == userID {
+			ids = append(ids, s.apiAuths[i].ID)
+		}
+	}
+	start, end, page := paginate("/api_authentication", ids, req.params)
+	rv := make([]*luno.APIAuth, 0, end-start)
+	for _, id := range ids[start:end] {
+		_, apiAuth := s.findAPIAuth(id)
+		rv = append(rv, s.apiAuthView(apiAuth, req.expand("user")))
+	}
+	return &list{Type: "list", URL: "/v1/api_authentication", List: rv, Page: page}, http.StatusOK, nil
+}
+
+func (s *Server) createAPIAuth(req *request) (interface{}, int, *luno.Error) {
+	var apiAuth luno.APIAuth
+	if err := req.decode(&apiAuth); err != nil {
+		return nil, 0, err
+	}
+	if apiAuth.UserID != "" {
+		if _, rec := s.findUser(apiAuth.UserID); rec == nil {
+			return nil, 0, errUserNotFound()
+		}
+	}
+	apiAuth.Entity = luno.Entity{Type: "api_authentication", ID: newID("api")}
+	apiAuth.URL = "/v1/api_authentication/" + apiAuth.ID
+	if apiAuth.Key == "" {
+		apiAuth.Key = randomString(24)
+	}
+	if apiAuth.Secret == "" {
+		apiAuth.Secret = randomString(48)
+	}
+	apiAuth.Created = s.timestamp()
+	apiAuth.User = nil
+	s.apiAuths = append(s.apiAuths, &apiAuth)
+	return s.apiAuthView(&apiAuth, req.expand("user")), http.StatusCreated, nil
+}
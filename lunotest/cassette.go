@@ -0,0 +1,384 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package lunotest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mschoch/luno-go"
+)
+
+// RecordEnv is the environment variable which, when set, makes
+// CassetteTransport record a new cassette instead of replaying
+const RecordEnv = "LUNO_RECORD"
+
+// scrubbed replaces the values of sensitive fields in cassettes
+const scrubbed = "SCRUBBED"
+
+// normalizedTime replaces timestamps in recorded request bodies
+const normalizedTime = "TIMESTAMP"
+
+// scrubbedParams are query parameters removed from recorded requests, they
+// differ on every request so would prevent matching
+var scrubbedParams = []string{"key", "timestamp", "sign"}
+
+// scrubbedPaths are path segments whose following segment, an id which is
+// also a credential, is replaced in recorded URLs
+var scrubbedPaths = map[string]bool{
+	"api_authentication": true,
+}
+
+// scrubbedFields are JSON keys whose values are replaced in recorded bodies
+var scrubbedFields = map[string]bool{
+	"password":         true,
+	"current_password": true,
+	"secret":           true,
+	"key":              true,
+}
+
+// keptHeaders are the response headers kept in cassettes
+var keptHeaders = []string{"Content-Type", "Retry-After"}
+
+// Cassette is a recording of requests to Luno and their responses
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Interaction is a single recorded request and response.  The request is
+// normalized, with the key, timestamp and signature removed, sensitive
+// values scrubbed from the bodies and timestamp values in the request body
+// replaced, so requests match regardless of when they are made.  API keys
+// in the path, as used by APIAuth.Get, are scrubbed, but other ids in the
+// path are kept, so an email or username given to Users.Get is recorded
+// as is.
+type Interaction struct {
+	Request struct {
+		Method string `json:"method"`
+		URL    string `json:"url"`
+		Body   string `json:"body,omitempty"`
+	} `json:"request"`
+	Response struct {
+		Status int         `json:"status"`
+		Header http.Header `json:"header,omitempty"`
+		Body   string      `json:"body,omitempty"`
+	} `json:"response"`
+
+	used bool
+}
+
+// LoadCassette reads a cassette from a file
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading cassette: %v", err)
+	}
+	var rv Cassette
+	err = json.Unmarshal(data, &rv)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing cassette json: %v", err)
+	}
+	return &rv, nil
+}
+
+// Save writes the cassette to a file, creating its directory if needed
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling cassette json: %v", err)
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return fmt.Errorf("error creating cassette dir: %v", err)
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Recorder is an http.RoundTripper which records the requests made through
+// it, and their responses, to a Cassette
+type Recorder struct {
+	next http.RoundTripper
+
+	m        sync.Mutex
+	cassette Cassette
+}
+
+// NewRecorder builds a new Recorder making requests with next, or
+// http.DefaultTransport if next is nil
+func NewRecorder(next http.RoundTripper) *Recorder {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Recorder{next: next}
+}
+
+// RoundTrip makes the request and records the interaction
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := luno.RequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	interaction := &Interaction{}
+	interaction.Request.Method = req.Method
+	interaction.Request.URL = normalizeURL(req.URL)
+	interaction.Request.Body = normalizeRequestBody(reqBody)
+	interaction.Response.Status = resp.StatusCode
+	for _, name := range keptHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			if interaction.Response.Header == nil {
+				interaction.Response.Header = make(http.Header)
+			}
+			interaction.Response.Header.Set(name, value)
+		}
+	}
+	interaction.Response.Body = normalizeBody(respBody)
+
+	r.m.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	r.m.Unlock()
+	return resp, nil
+}
+
+// Cassette returns the interactions recorded so far
+func (r *Recorder) Cassette() *Cassette {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return &Cassette{Interactions: append([]*Interaction(nil), r.cassette.Interactions...)}
+}
+
+// Save writes the interactions recorded so far to a file
+func (r *Recorder) Save(path string) error {
+	return r.Cassette().Save(path)
+}
+
+// Replayer is an http.RoundTripper which responds to requests from a
+// Cassette, without making them.  Requests are matched by method, URL and
+// body, after normalizing as when recorded, and each interaction is used
+// once, in order.  Requests with no matching interaction fail.
+type Replayer struct {
+	m        sync.Mutex
+	cassette *Cassette
+}
+
+// NewReplayer builds a new Replayer responding from the cassette
+func NewReplayer(cassette *Cassette) *Replayer {
+	return &Replayer{cassette: cassette}
+}
+
+// RoundTrip responds with the first unused matching interaction
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := luno.RequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	reqURL := normalizeURL(req.URL)
+	body := normalizeRequestBody(reqBody)
+
+	r.m.Lock()
+	defer r.m.Unlock()
+	for _, interaction := range r.cassette.Interactions {
+		if interaction.used || interaction.Request.Method != req.Method ||
+			interaction.Request.URL != reqURL || interaction.Request.Body != body {
+			continue
+		}
+		interaction.used = true
+		header := make(http.Header)
+		for name, values := range interaction.Response.Header {
+			header[name] = append([]string(nil), values...)
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Response.Status, http.StatusText(interaction.Response.Status)),
+			StatusCode:    interaction.Response.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          ioutil.NopCloser(strings.NewReader(interaction.Response.Body)),
+			ContentLength: int64(len(interaction.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+	if body != "" {
+		return nil, fmt.Errorf("lunotest: no recorded interaction for %s %s with body %s", req.Method, reqURL, body)
+	}
+	return nil, fmt.Errorf("lunotest: no recorded interaction for %s %s", req.Method, reqURL)
+}
+
+// Unused returns the interactions which have not been replayed
+func (r *Replayer) Unused() []*Interaction {
+	r.m.Lock()
+	defer r.m.Unlock()
+	var rv []*Interaction
+	for _, interaction := range r.cassette.Interactions {
+		if !interaction.used {
+			rv = append(rv, interaction)
+		}
+	}
+	return rv
+}
+
+// CassetteTransport returns a transport for use with luno.WithTransport in
+// tests.  If RecordEnv is set, requests are made to Luno and recorded, and
+// the cassette is saved to path when the test completes.  Otherwise the
+// cassette at path is replayed, and the test fails if a request has no
+// matching interaction, or if any interaction is not used.
+func CassetteTransport(tb testing.TB, path string) http.RoundTripper {
+	tb.Helper()
+	if os.Getenv(RecordEnv) != "" {
+		recorder := NewRecorder(nil)
+		tb.Cleanup(func() {
+			if err := recorder.Save(path); err != nil {
+				tb.Errorf("error saving cassette: %v", err)
+			}
+		})
+		return recorder
+	}
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		tb.Fatalf("%v, set %s to record it", err, RecordEnv)
+	}
+	replayer := NewReplayer(cassette)
+	tb.Cleanup(func() {
+		for _, interaction := range replayer.Unused() {
+			tb.Errorf("lunotest: recorded interaction not used: %s %s", interaction.Request.Method, interaction.Request.URL)
+		}
+	})
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := replayer.RoundTrip(req)
+		if err != nil {
+			tb.Error(err)
+		}
+		return resp, err
+	})
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// normalizeURL returns the path and query of u, without the parameters
+// which change on every request, with the query in a canonical order and
+// credentials in the path scrubbed
+func normalizeURL(u *url.URL) string {
+	path, rawQuery := u.EscapedPath(), u.RawQuery
+	if u.Opaque != "" {
+		path, rawQuery, _ = strings.Cut(u.Opaque, "?")
+	}
+	segments := strings.Split(path, "/")
+	for i := 1; i < len(segments); i++ {
+		if scrubbedPaths[segments[i-1]] {
+			segments[i] = scrubbed
+		}
+	}
+	path = strings.Join(segments, "/")
+	params, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return path + "?" + rawQuery
+	}
+	for _, name := range scrubbedParams {
+		params.Del(name)
+	}
+	if len(params) == 0 {
+		return path
+	}
+	return path + "?" + params.Encode()
+}
+
+// normalizeBody returns JSON bodies in a canonical form, with sensitive
+// values scrubbed, other bodies are returned as is
+func normalizeBody(body []byte) string {
+	return normalizeJSON(body, scrub)
+}
+
+// normalizeRequestBody is normalizeBody with timestamp values also
+// replaced, as those built from time.Now differ on every run so would
+// prevent matching.  Only RFC 3339 strings are recognized, other time
+// formats must match exactly.
+func normalizeRequestBody(body []byte) string {
+	return normalizeJSON(body, func(val interface{}) interface{} {
+		return normalizeTimes(scrub(val))
+	})
+}
+
+func normalizeJSON(body []byte, normalize func(interface{}) interface{}) string {
+	if len(body) == 0 {
+		return ""
+	}
+	var val interface{}
+	if json.Unmarshal(body, &val) != nil {
+		return string(body)
+	}
+	rv, err := json.Marshal(normalize(val))
+	if err != nil {
+		return string(body)
+	}
+	return string(rv)
+}
+
+func scrub(val interface{}) interface{} {
+	switch val := val.(type) {
+	case map[string]interface{}:
+		for k, v := range val {
+			if _, ok := v.(string); ok && scrubbedFields[k] {
+				val[k] = scrubbed
+			} else {
+				val[k] = scrub(v)
+			}
+		}
+	case []interface{}:
+		for i, v := range val {
+			val[i] = scrub(v)
+		}
+	}
+	return val
+}
+
+func normalizeTimes(val interface{}) interface{} {
+	switch val := val.(type) {
+	case map[string]interface{}:
+		for k, v := range val {
+			val[k] = normalizeTimes(v)
+		}
+	case []interface{}:
+		for i, v := range val {
+			val[i] = normalizeTimes(v)
+		}
+	case string:
+		if _, err := time.Parse(time.RFC3339Nano, val); err == nil {
+			return normalizedTime
+		}
+	}
+	return val
+}
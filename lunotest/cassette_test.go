@@ -0,0 +1,174 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package lunotest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mschoch/luno-go"
+)
+
+func TestCassetteRecordReplay(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	path := filepath.Join(t.TempDir(), "cassettes", "users.json")
+
+	recorder := NewRecorder(nil)
+	lunoClient := s.Client(luno.WithTransport(recorder))
+	user, err := lunoClient.Users.Create(&luno.User{Email: "marty@example.com", Password: "hunter22"}, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiAuth, err := lunoClient.APIAuth.Create(&luno.APIAuth{UserID: user.ID}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = lunoClient.Users.Get(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = lunoClient.Users.Get("usr_missing")
+	if !luno.IsErrorCode(err, luno.ErrCodeUserNotFound) {
+		t.Fatalf("expected user not found, got %v", err)
+	}
+	err = recorder.Save(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"hunter22", s.APIKey, s.SecretKey, apiAuth.Key, apiAuth.Secret, "sign=", "timestamp="} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("expected %q to be scrubbed from cassette", secret)
+		}
+	}
+
+	// replay, with different credentials and no server
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayer := NewReplayer(cassette)
+	replayClient := luno.NewClient("other-key", "other-secret",
+		luno.WithBaseURL("https://luno.invalid"),
+		luno.WithTransport(replayer),
+		luno.WithRetryPolicy(luno.RetryPolicy{MaxAttempts: 1}))
+	replayed, err := replayClient.Users.Create(&luno.User{Email: "marty@example.com", Password: "hunter22"}, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.ID != user.ID {
+		t.Errorf("expected replayed user %s, got %s", user.ID, replayed.ID)
+	}
+	if len(replayer.Unused()) != 3 {
+		t.Errorf("expected 3 unused interactions, got %d", len(replayer.Unused()))
+	}
+	_, err = replayClient.Users.Get(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = replayClient.Users.Get("usr_missing")
+	if !luno.IsErrorCode(err, luno.ErrCodeUserNotFound) {
+		t.Errorf("expected replayed user not found, got %v", err)
+	}
+
+	// each interaction is used once
+	_, err = replayClient.Users.Get(user.ID)
+	if err == nil || !strings.Contains(err.Error(), "no recorded interaction for GET /v1/users/"+user.ID) {
+		t.Errorf("expected unmatched request to fail, got %v", err)
+	}
+	// requests must match on body
+	_, err = replayClient.APIAuth.Create(&luno.APIAuth{UserID: "usr_other"}, nil)
+	if err == nil {
+		t.Errorf("expected request with different body to fail")
+	}
+}
+
+func TestCassetteReplayTimestamps(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	recorder := NewRecorder(nil)
+	recorded := time.Date(2016, 1, 2, 15, 4, 5, 123, time.UTC)
+	_, err := s.Client(luno.WithTransport(recorder)).Events.Create(&luno.Event{
+		Name:      "Page View",
+		Timestamp: luno.NewTime(recorded),
+		Details:   map[string]interface{}{"at": recorded.Format(time.RFC3339)},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the same request made at a different time matches
+	replayer := NewReplayer(recorder.Cassette())
+	replayClient := luno.NewClient("other-key", "other-secret",
+		luno.WithBaseURL("https://luno.invalid"), luno.WithTransport(replayer))
+	later := recorded.Add(time.Hour)
+	event, err := replayClient.Events.Create(&luno.Event{
+		Name:      "Page View",
+		Timestamp: luno.NewTime(later),
+		Details:   map[string]interface{}{"at": later.Format(time.RFC3339)},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Name != "Page View" {
+		t.Errorf("expected replayed event, got %+v", event)
+	}
+}
+
+func TestCassetteScrubsAPIKeyPath(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	path := filepath.Join(t.TempDir(), "api_auth.json")
+
+	apiAuth, err := s.Client().APIAuth.Create(&luno.APIAuth{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := NewRecorder(nil)
+	_, err = s.Client(luno.WithTransport(recorder)).APIAuth.Get(apiAuth.Key, []string{"user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = recorder.Save(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), apiAuth.Key) {
+		t.Errorf("expected api key to be scrubbed from cassette, got %s", data)
+	}
+	if !strings.Contains(string(data), "/api_authentication/"+scrubbed) {
+		t.Errorf("expected scrubbed api key path in cassette, got %s", data)
+	}
+
+	// a lookup of any key replays the recorded response
+	replayer := NewReplayer(recorder.Cassette())
+	replayClient := luno.NewClient("other-key", "other-secret",
+		luno.WithBaseURL("https://luno.invalid"), luno.WithTransport(replayer))
+	_, err = replayClient.APIAuth.Get("another-key", []string{"user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
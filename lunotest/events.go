@@ -0,0 +1,127 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package lunotest
+
+import (
+	"net/http"
+
+	"github.com/mschoch/luno-go"
+)
+
+// findEvent finds an event by id, returning the index in s.events
+func (s *Server) findEvent(id string) (int, *luno.Event) {
+	for i, event := range s.events {
+		if event.ID == id {
+			return i, event
+		}
+	}
+	return -1, nil
+}
+
+// eventView returns a copy of the event, with the user expanded if
+// requested
+func (s *Server) eventView(event *luno.Event, expandUser bool) *luno.Event {
+	rv := *event
+	rv.User = nil
+	if expandUser && event.UserID != "" {
+		if _, rec := s.findUser(event.UserID); rec != nil {
+			rv.User = userCopy(rec.user)
+		}
+	}
+	return &rv
+}
+
+// addEvent records an event generated by Luno itself, such as "User Created"
+func (s *Server) addEvent(userID, name string) {
+	s.storeEvent(&luno.Event{UserID: userID, Name: name})
+}
+
+func (s *Server) storeEvent(event *luno.Event) {
+	event.Entity = luno.Entity{Type: "event", ID: newID("evt")}
+	event.URL = "/v1/events/" + event.ID
+	if event.Timestamp.IsZero() {
+		event.Timestamp = s.timestamp()
+	}
+	event.User = nil
+	s.events = append(s.events, event)
+}
+
+func (s *Server) handleEvents(req *request) (interface{}, int, *luno.Error) {
+	switch {
+	case len(req.parts) == 0 && req.method() == http.MethodGet:
+		return s.recentEvents(req)
+	case len(req.parts) == 0 && req.method() == http.MethodPost:
+		return s.createEvent(req)
+	case len(req.parts) != 1:
+		return nil, 0, errMethodNotAllowed()
+	}
+
+	i, event := s.findEvent(req.parts[0])
+	if event == nil {
+		return nil, 0, &luno.Error{Code: luno.ErrCodeEventNotFound, Message: "Event not found", Status: http.StatusNotFound}
+	}
+	switch req.method() {
+	case http.MethodGet:
+		return s.eventView(event, req.expand("user")), http.StatusOK, nil
+	case http.MethodPut, http.MethodPatch:
+		var update luno.Event
+		if err := req.decode(&update); err != nil {
+			return nil, 0, err
+		}
+		if req.method() == http.MethodPut {
+			event.Details = update.Details
+		} else if update.Details != nil {
+			event.Details = mergeDetails(event.Details, update.Details)
+		}
+		return s.eventView(event, req.expand("user")), http.StatusOK, nil
+	case http.MethodDelete:
+		s.events = append(s.events[:i], s.events[i+1:]...)
+		return success, http.StatusOK, nil
+	}
+	return nil, 0, errMethodNotAllowed()
+}
+
+func (s *Server) recentEvents(req *request) (interface{}, int, *luno.Error) {
+	userID := req.params.Get("user_id")
+	name := req.params.Get("name")
+	var ids []string
+	for i := len(s.events) - 1; i >= 0; i-- {
+		event := s.events[i]
+		if (userID == "" || event.UserID == userID) && (name == "" || event.Name == name) {
+			ids = append(ids, event.ID)
+		}
+	}
+	start, end, page := paginate("/events", ids, req.params)
+	rv := make([]*luno.Event, 0, end-start)
+	for _, id := range ids[start:end] {
+		_, event := s.findEvent(id)
+		rv = append(rv, s.eventView(event, req.expand("user")))
+	}
+	return &list{Type: "list", URL: "/v1/events", List: rv, Page: page}, http.StatusOK, nil
+}
+
+func (s *Server) createEvent(req *request) (interface{}, int, *luno.Error) {
+	var event luno.Event
+	if err := req.decode(&event); err != nil {
+		return nil, 0, err
+	}
+	if event.Name == "" {
+		return nil, 0, errInvalidParams("name is required")
+	}
+	if event.UserID != "" {
+		if _, rec := s.findUser(event.UserID); rec == nil {
+			return nil, 0, errUserNotFound()
+		}
+	}
+	s.storeEvent(&event)
+	return s.eventView(&event, req.expand("user")), http.StatusCreated, nil
+}
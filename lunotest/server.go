@@ -0,0 +1,367 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+// Package lunotest provides an in-memory fake of the Luno API, for testing
+// applications built with luno-go without a Luno account or network access.
+//
+// The fake implements the users, sessions, events, api_authentication,
+// analytics and account endpoints, including paging, expand, Luno error
+// codes and verification of request signatures.  It aims to be realistic,
+// but it is not a complete reimplementation of Luno.
+//
+// For tests which need the real Luno API, CassetteTransport records
+// interactions with Luno once, and replays them in later runs.
+package lunotest
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mschoch/luno-go"
+)
+
+// DefaultLimit is the page size used when a request does not specify one
+const DefaultLimit = 100
+
+// TimestampSkew is how far the timestamp of a signed request may be from the
+// current time
+const TimestampSkew = 5 * time.Minute
+
+// Server is an in-memory fake of the Luno API, running on a local HTTP
+// server
+type Server struct {
+	// URL is the base URL of the server, suitable for luno.WithBaseURL
+	URL string
+	// APIKey and SecretKey are the credentials the server accepts
+	APIKey    string
+	SecretKey string
+	// Now returns the time used for created, expires and similar fields,
+	// it may be replaced before the server is used
+	Now func() time.Time
+
+	server *httptest.Server
+
+	m        sync.Mutex
+	users    []*userRecord
+	sessions []*luno.Session
+	events   []*luno.Event
+	apiAuths []*luno.APIAuth
+	account  *luno.Account
+}
+
+// NewServer starts a new fake Luno server with random credentials, callers
+// should Close the server when finished with it
+func NewServer() *Server {
+	rv := &Server{
+		APIKey:    randomString(24),
+		SecretKey: randomString(48),
+		Now:       time.Now,
+		account: &luno.Account{
+			Entity:    luno.Entity{Type: "account", ID: "acc_" + randomString(24), URL: "/v1/account"},
+			Email:     "owner@example.com",
+			Name:      "Test Owner",
+			FirstName: "Test",
+			LastName:  "Owner",
+			UserName:  "owner",
+		},
+	}
+	rv.account.Created = rv.timestamp()
+	rv.server = httptest.NewServer(rv)
+	rv.URL = rv.server.URL
+	return rv
+}
+
+// NewClient starts a new fake Luno server and returns a luno.Client
+// configured to use it, along with the server itself.  The server is closed
+// when the test completes.
+func NewClient(tb testing.TB, opts ...luno.Option) (*luno.Client, *Server) {
+	s := NewServer()
+	tb.Cleanup(s.Close)
+	return s.Client(opts...), s
+}
+
+// Client returns a new luno.Client configured to use this server, the
+// options are applied after those pointing the client at the server
+func (s *Server) Client(opts ...luno.Option) *luno.Client {
+	opts = append([]luno.Option{luno.WithBaseURL(s.URL)}, opts...)
+	return luno.NewClient(s.APIKey, s.SecretKey, opts...)
+}
+
+// Close shuts down the server
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// ServeHTTP verifies the signature of the request and dispatches it to the
+// handler for the resource
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, errInvalidParams("unable to read request body"))
+		return
+	}
+	lerr := s.verify(r, body)
+	if lerr != nil {
+		writeError(w, lerr)
+		return
+	}
+
+	path := strings.Trim(r.URL.Path, "/")
+	if !strings.HasPrefix(path, "v1/") {
+		writeError(w, errNotFound())
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(path, "v1/"), "/")
+	req := &request{r: r, params: r.URL.Query(), body: body, parts: parts[1:]}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+	var rv interface{}
+	var status int
+	switch parts[0] {
+	case "users":
+		rv, status, lerr = s.handleUsers(req)
+	case "sessions":
+		rv, status, lerr = s.handleSessions(req)
+	case "events":
+		rv, status, lerr = s.handleEvents(req)
+	case "api_authentication":
+		rv, status, lerr = s.handleAPIAuths(req)
+	case "analytics":
+		rv, status, lerr = s.handleAnalytics(req)
+	case "account":
+		rv, status, lerr = s.handleAccount(req)
+	default:
+		lerr = errNotFound()
+	}
+	if lerr != nil {
+		writeError(w, lerr)
+		return
+	}
+	writeJSON(w, status, rv)
+}
+
+// verify checks the key, timestamp and signature of a request, see
+// luno.Signer for the details of the scheme
+func (s *Server) verify(r *http.Request, body []byte) *luno.Error {
+	verifier := luno.Verifier{MaxSkew: TimestampSkew}
+	signed, err := verifier.Parse(r, body)
+	if err == nil && signed.Key != s.APIKey {
+		err = &luno.Error{Code: luno.ErrCodeInvalidAPIKey, Message: "Invalid API key", Status: http.StatusUnauthorized}
+	}
+	if err == nil {
+		err = signed.Verify(s.SecretKey)
+	}
+	if err != nil {
+		lerr, ok := err.(*luno.Error)
+		if !ok {
+			lerr = &luno.Error{Code: luno.ErrCodeInvalidSignature, Message: err.Error(), Status: http.StatusUnauthorized}
+		}
+		return lerr
+	}
+	return nil
+}
+
+// request is a verified request to the fake server
+type request struct {
+	r      *http.Request
+	params url.Values
+	body   []byte
+	parts  []string
+}
+
+func (r *request) method() string {
+	return r.r.Method
+}
+
+func (r *request) decode(v interface{}) *luno.Error {
+	if len(r.body) == 0 {
+		return nil
+	}
+	err := json.Unmarshal(r.body, v)
+	if err != nil {
+		return errInvalidParams(fmt.Sprintf("invalid json: %v", err))
+	}
+	return nil
+}
+
+func (r *request) boolParam(name string) bool {
+	rv, _ := strconv.ParseBool(r.params.Get(name))
+	return rv
+}
+
+func (r *request) expand(name string) bool {
+	for _, expand := range r.params["expand"] {
+		if expand == name {
+			return true
+		}
+	}
+	return false
+}
+
+// list is the shape of all Luno list responses
+type list struct {
+	Type string      `json:"type"`
+	URL  string      `json:"url"`
+	List interface{} `json:"list"`
+	Page page        `json:"page"`
+}
+
+type page struct {
+	Next *luno.Entity `json:"next"`
+	Prev *luno.Entity `json:"prev"`
+}
+
+// paginate selects the page of ids requested by the from, to and limit
+// parameters, ids must be ordered newest first.  It returns the selected
+// range of indexes and the page links.
+func paginate(endpoint string, ids []string, params url.Values) (int, int, page) {
+	limit, err := strconv.Atoi(params.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = DefaultLimit
+	}
+	start := 0
+	if from := params.Get("from"); from != "" {
+		start = len(ids)
+		for i, id := range ids {
+			if id == from {
+				start = i
+				break
+			}
+		}
+	}
+	end := start + limit
+	if end > len(ids) {
+		end = len(ids)
+	}
+	if to := params.Get("to"); to != "" {
+		for i := start; i < end; i++ {
+			if ids[i] == to {
+				end = i + 1
+				break
+			}
+		}
+	}
+	var rv page
+	if end < len(ids) {
+		rv.Next = &luno.Entity{
+			Type: "page",
+			ID:   ids[end],
+			URL:  fmt.Sprintf("/v1%s?from=%s&limit=%d", endpoint, url.QueryEscape(ids[end]), limit),
+		}
+	}
+	if start > 0 && start <= len(ids) {
+		prev := start - limit
+		if prev < 0 {
+			prev = 0
+		}
+		rv.Prev = &luno.Entity{
+			Type: "page",
+			ID:   ids[prev],
+			URL:  fmt.Sprintf("/v1%s?from=%s&limit=%d", endpoint, url.QueryEscape(ids[prev]), limit),
+		}
+	}
+	return start, end, rv
+}
+
+// timestamp returns the current time as a luno.Time
+func (s *Server) timestamp() luno.Time {
+	return luno.NewTime(s.Now())
+}
+
+// mergeDetails implements PATCH semantics for details and profiles, the
+// keys of update are added to, or replace those in, orig
+func mergeDetails(orig, update interface{}) interface{} {
+	origMap, ok := orig.(map[string]interface{})
+	if !ok {
+		return update
+	}
+	updateMap, ok := update.(map[string]interface{})
+	if !ok {
+		return update
+	}
+	rv := make(map[string]interface{}, len(origMap)+len(updateMap))
+	for k, v := range origMap {
+		rv[k] = v
+	}
+	for k, v := range updateMap {
+		rv[k] = v
+	}
+	return rv
+}
+
+// splitName derives a first and last name from a full name, as Luno does
+// when auto_name is true
+func splitName(name string) (string, string) {
+	fields := strings.Fields(name)
+	if len(fields) == 0 {
+		return "", ""
+	}
+	return fields[0], strings.Join(fields[1:], " ")
+}
+
+const idChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// randomString returns a random alphanumeric string of length n
+func randomString(n int) string {
+	buf := make([]byte, n)
+	_, err := rand.Read(buf)
+	if err != nil {
+		panic(err)
+	}
+	for i, b := range buf {
+		buf[i] = idChars[int(b)%len(idChars)]
+	}
+	return string(buf)
+}
+
+// newID returns a new Luno style id with the provided prefix
+func newID(prefix string) string {
+	return prefix + "_" + randomString(24)
+}
+
+var success = map[string]interface{}{"success": true}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Request-Id", newID("req"))
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err *luno.Error) {
+	if err.Message == "" {
+		err.Message = http.StatusText(err.Status)
+	}
+	writeJSON(w, err.Status, err)
+}
+
+func errNotFound() *luno.Error {
+	return &luno.Error{Code: luno.ErrCodeNotFound, Message: "Not found", Status: http.StatusNotFound}
+}
+
+func errMethodNotAllowed() *luno.Error {
+	return &luno.Error{Code: luno.ErrCodeMethodNotAllowed, Message: "Method not allowed", Status: http.StatusMethodNotAllowed}
+}
+
+func errInvalidParams(description string) *luno.Error {
+	return &luno.Error{Code: luno.ErrCodeInvalidParams, Message: "Invalid parameters", Description: description, Status: http.StatusBadRequest}
+}
@@ -0,0 +1,117 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package lunotest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/mschoch/luno-go"
+)
+
+func TestSignatureVerification(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	lunoClient := luno.NewClient(s.APIKey, "wrong-secret", luno.WithBaseURL(s.URL))
+	_, err := lunoClient.Account.Get()
+	if !luno.IsErrorCode(err, luno.ErrCodeInvalidSignature) {
+		t.Errorf("expected invalid signature, got %v", err)
+	}
+
+	lunoClient = luno.NewClient("wrong-key", s.SecretKey, luno.WithBaseURL(s.URL))
+	_, err = lunoClient.Account.Get()
+	if !luno.IsErrorCode(err, luno.ErrCodeInvalidAPIKey) {
+		t.Errorf("expected invalid api key, got %v", err)
+	}
+
+	_, err = s.Client().Account.Get()
+	if err != nil {
+		t.Errorf("expected correctly signed request to succeed, got %v", err)
+	}
+}
+
+func TestPaging(t *testing.T) {
+	lunoClient, _ := NewClient(t)
+	for i := 0; i < 25; i++ {
+		_, err := lunoClient.Users.Create(&luno.User{Email: fmt.Sprintf("user%d@example.com", i)}, false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	users, err := lunoClient.Users.Recent(nil, &luno.Paging{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users.List) != 10 {
+		t.Errorf("expected 10 users on first page, got %d", len(users.List))
+	}
+	if users.List[0].Email != "user24@example.com" {
+		t.Errorf("expected newest user first, got %s", users.List[0].Email)
+	}
+
+	count := 0
+	seen := make(map[string]bool)
+	for user, err := range lunoClient.Users.All(context.Background(), nil, &luno.Paging{Limit: 10}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[user.ID] {
+			t.Errorf("saw user %s twice", user.ID)
+		}
+		seen[user.ID] = true
+		count++
+	}
+	if count != 25 {
+		t.Errorf("expected 25 users, got %d", count)
+	}
+}
+
+func TestExpandAndConflicts(t *testing.T) {
+	lunoClient, _ := NewClient(t)
+	user, err := lunoClient.Users.Create(&luno.User{Name: "Ada Lovelace", Email: "ada@example.com", Password: "engine"}, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.FirstName != "Ada" || user.LastName != "Lovelace" {
+		t.Errorf("expected auto name Ada Lovelace, got '%s' '%s'", user.FirstName, user.LastName)
+	}
+	if user.Password != "" {
+		t.Errorf("expected password not to be returned")
+	}
+
+	_, err = lunoClient.Users.Create(&luno.User{Email: "ada@example.com"}, false, nil)
+	if !luno.IsErrorCode(err, luno.ErrCodeEmailTaken) {
+		t.Errorf("expected email taken, got %v", err)
+	}
+
+	_, session, err := lunoClient.Users.LoginWithAny("ada@example.com", "engine", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err = lunoClient.Sessions.Get(session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.User != nil {
+		t.Errorf("expected user not to be expanded")
+	}
+	sessions, err := lunoClient.Sessions.Recent([]string{"user"}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions.List) != 1 || sessions.List[0].User == nil || sessions.List[0].User.ID != user.ID {
+		t.Errorf("expected 1 session with expanded user %s, got %v", user.ID, sessions.List)
+	}
+}
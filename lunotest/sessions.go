@@ -0,0 +1,196 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package lunotest
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/mschoch/luno-go"
+)
+
+// DefaultSessionTTL is how long a session lasts if it is created without an
+// expiry
+const DefaultSessionTTL = 30 * 24 * time.Hour
+
+func errSessionNotFound() *luno.Error {
+	return &luno.Error{Code: luno.ErrCodeSessionNotFound, Message: "Session not found", Status: http.StatusNotFound}
+}
+
+// findSession finds a session by id, returning the index in s.sessions
+func (s *Server) findSession(id string) (int, *luno.Session) {
+	for i, session := range s.sessions {
+		if session.ID == id {
+			return i, session
+		}
+	}
+	return -1, nil
+}
+
+// sessionView returns a copy of the session, with the user expanded if
+// requested
+func (s *Server) sessionView(session *luno.Session, expandUser bool) *luno.Session {
+	rv := *session
+	rv.User = nil
+	if expandUser && session.UserID != "" {
+		if _, rec := s.findUser(session.UserID); rec != nil {
+			rv.User = userCopy(rec.user)
+		}
+	}
+	return &rv
+}
+
+// storeSession assigns the session an id, key and timestamps and stores it
+func (s *Server) storeSession(session *luno.Session) {
+	now := s.Now()
+	session.Entity = luno.Entity{Type: "session", ID: newID("sess")}
+	session.URL = "/v1/sessions/" + session.ID
+	if session.Key == "" {
+		session.Key = randomString(64)
+	}
+	session.Created = luno.NewTime(now)
+	if session.Expires.IsZero() {
+		session.Expires = luno.NewTime(now.Add(DefaultSessionTTL))
+	}
+	session.LastAccess = luno.Time{}
+	session.AccessCount = 0
+	session.User = nil
+	s.sessions = append(s.sessions, session)
+	if session.UserID != "" {
+		s.addEvent(session.UserID, "Session Created")
+	}
+}
+
+func (s *Server) deleteUserSessions(userID string) {
+	sessions := s.sessions[:0]
+	for _, session := range s.sessions {
+		if session.UserID != userID {
+			sessions = append(sessions, session)
+		}
+	}
+	s.sessions = sessions
+}
+
+func (s *Server) handleSessions(req *request) (interface{}, int, *luno.Error) {
+	switch {
+	case len(req.parts) == 0 && req.method() == http.MethodGet:
+		return s.recentSessions(req)
+	case len(req.parts) == 0 && req.method() == http.MethodPost:
+		return s.createSession(req)
+	case len(req.parts) == 1 && req.parts[0] == "access" && req.method() == http.MethodPost:
+		return s.accessSession(req)
+	case len(req.parts) != 1 || req.parts[0] == "access":
+		return nil, 0, errMethodNotAllowed()
+	}
+
+	i, session := s.findSession(req.parts[0])
+	if session == nil {
+		return nil, 0, errSessionNotFound()
+	}
+	switch req.method() {
+	case http.MethodGet:
+		return s.sessionView(session, req.expand("user")), http.StatusOK, nil
+	case http.MethodPut, http.MethodPatch:
+		var update luno.Session
+		if err := req.decode(&update); err != nil {
+			return nil, 0, err
+		}
+		s.applySessionUpdate(session, &update, req.method() == http.MethodPut)
+		return s.sessionView(session, req.expand("user")), http.StatusOK, nil
+	case http.MethodDelete:
+		s.sessions = append(s.sessions[:i], s.sessions[i+1:]...)
+		return success, http.StatusOK, nil
+	}
+	return nil, 0, errMethodNotAllowed()
+}
+
+func (s *Server) recentSessions(req *request) (interface{}, int, *luno.Error) {
+	userID := req.params.Get("user_id")
+	var ids []string
+	for i := len(s.sessions) - 1; i >= 0; i-- {
+		if userID == "" || s.sessions[i].UserID == userID {
+			ids = append(ids, s.sessions[i].ID)
+		}
+	}
+	start, end, page := paginate("/sessions", ids, req.params)
+	rv := make([]*luno.Session, 0, end-start)
+	for _, id := range ids[start:end] {
+		_, session := s.findSession(id)
+		rv = append(rv, s.sessionView(session, req.expand("user")))
+	}
+	return &list{Type: "list", URL: "/v1/sessions", List: rv, Page: page}, http.StatusOK, nil
+}
+
+func (s *Server) createSession(req *request) (interface{}, int, *luno.Error) {
+	var session luno.Session
+	if err := req.decode(&session); err != nil {
+		return nil, 0, err
+	}
+	if session.UserID != "" {
+		if _, rec := s.findUser(session.UserID); rec == nil {
+			return nil, 0, errUserNotFound()
+		}
+	}
+	s.storeSession(&session)
+	return s.sessionView(&session, req.expand("user")), http.StatusCreated, nil
+}
+
+// applySessionUpdate applies the fields present in update to the session,
+// details are replaced when overwrite is set, otherwise merged
+func (s *Server) applySessionUpdate(session, update *luno.Session, overwrite bool) {
+	if update.UserID != "" {
+		session.UserID = update.UserID
+	}
+	if !update.Expires.IsZero() {
+		session.Expires = update.Expires
+	}
+	if update.IP != "" {
+		session.IP = update.IP
+	}
+	if update.UserAgent != "" {
+		session.UserAgent = update.UserAgent
+	}
+	if update.Details != nil {
+		if overwrite {
+			session.Details = update.Details
+		} else {
+			session.Details = mergeDetails(session.Details, update.Details)
+		}
+	}
+}
+
+func (s *Server) accessSession(req *request) (interface{}, int, *luno.Error) {
+	var access luno.Session
+	if err := req.decode(&access); err != nil {
+		return nil, 0, err
+	}
+	if access.Key == "" {
+		return nil, 0, errInvalidParams("key is required")
+	}
+	var session *luno.Session
+	for _, candidate := range s.sessions {
+		if candidate.Key == access.Key {
+			session = candidate
+			break
+		}
+	}
+	if session == nil {
+		return nil, 0, errSessionNotFound()
+	}
+	if session.IsExpired(s.Now()) {
+		return nil, 0, &luno.Error{Code: luno.ErrCodeSessionExpired, Message: "Session expired", Status: http.StatusForbidden}
+	}
+	s.applySessionUpdate(session, &access, false)
+	session.AccessCount++
+	session.LastAccess = s.timestamp()
+	return s.sessionView(session, req.expand("user")), http.StatusOK, nil
+}
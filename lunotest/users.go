@@ -0,0 +1,311 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package lunotest
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/mschoch/luno-go"
+)
+
+// userRecord is a stored user along with their password, which is never
+// returned by the API
+type userRecord struct {
+	user     *luno.User
+	password string
+}
+
+func errUserNotFound() *luno.Error {
+	return &luno.Error{Code: luno.ErrCodeUserNotFound, Message: "User not found", Status: http.StatusNotFound}
+}
+
+func errIncorrectPassword() *luno.Error {
+	return &luno.Error{Code: luno.ErrCodeIncorrectPassword, Message: "Incorrect password", Status: http.StatusUnauthorized}
+}
+
+// findUser finds a user by id, or by "email:" or "username:" prefixed
+// identifier, returning the index in s.users
+func (s *Server) findUser(ident string) (int, *userRecord) {
+	field, value := "id", ident
+	if pos := strings.Index(ident, ":"); pos > 0 {
+		field, value = ident[:pos], ident[pos+1:]
+	}
+	for i, rec := range s.users {
+		switch field {
+		case "id":
+			if rec.user.ID == value {
+				return i, rec
+			}
+		case "email":
+			if rec.user.Email != "" && strings.EqualFold(rec.user.Email, value) {
+				return i, rec
+			}
+		case "username":
+			if rec.user.UserName != "" && strings.EqualFold(rec.user.UserName, value) {
+				return i, rec
+			}
+		}
+	}
+	return -1, nil
+}
+
+// conflict checks if the email or username are in use by a user other than
+// the one with id
+func (s *Server) conflict(id, email, username string) *luno.Error {
+	for _, rec := range s.users {
+		if rec.user.ID == id {
+			continue
+		}
+		if email != "" && strings.EqualFold(rec.user.Email, email) {
+			return &luno.Error{Code: luno.ErrCodeEmailTaken, Message: "Email already taken", Status: http.StatusConflict}
+		}
+		if username != "" && strings.EqualFold(rec.user.UserName, username) {
+			return &luno.Error{Code: luno.ErrCodeUsernameTaken, Message: "Username already taken", Status: http.StatusConflict}
+		}
+	}
+	return nil
+}
+
+// userCopy returns a copy of the user, safe to encode after the lock is
+// released
+func userCopy(user *luno.User) *luno.User {
+	rv := *user
+	return &rv
+}
+
+func (s *Server) handleUsers(req *request) (interface{}, int, *luno.Error) {
+	switch {
+	case len(req.parts) == 0 && req.method() == http.MethodGet:
+		return s.recentUsers(req)
+	case len(req.parts) == 0 && req.method() == http.MethodPost:
+		return s.createUser(req)
+	case len(req.parts) == 1 && req.parts[0] == "login" && req.method() == http.MethodPost:
+		return s.login(req)
+	case len(req.parts) == 0 || len(req.parts) == 1 && req.parts[0] == "login":
+		return nil, 0, errMethodNotAllowed()
+	}
+
+	i, rec := s.findUser(req.parts[0])
+	if rec == nil {
+		return nil, 0, errUserNotFound()
+	}
+	action := strings.Join(req.parts[1:], "/")
+	switch {
+	case action == "" && req.method() == http.MethodGet:
+		return userCopy(rec.user), http.StatusOK, nil
+	case action == "" && (req.method() == http.MethodPut || req.method() == http.MethodPatch):
+		return s.updateUser(req, rec)
+	case action == "" && req.method() == http.MethodDelete:
+		if req.boolParam("permanent") {
+			s.deleteUser(i)
+		} else {
+			rec.user.Closed = s.timestamp()
+		}
+		return success, http.StatusOK, nil
+	case action == "reactivate" && req.method() == http.MethodPost:
+		rec.user.Closed = luno.Time{}
+		return userCopy(rec.user), http.StatusOK, nil
+	case action == "sessions" && req.method() == http.MethodDelete:
+		s.deleteUserSessions(rec.user.ID)
+		return success, http.StatusOK, nil
+	case action == "password/validate" && req.method() == http.MethodPost:
+		var validate struct {
+			Password string `json:"password"`
+		}
+		if err := req.decode(&validate); err != nil {
+			return nil, 0, err
+		}
+		if validate.Password != rec.password {
+			return nil, 0, errIncorrectPassword()
+		}
+		return success, http.StatusOK, nil
+	case action == "password/change" && req.method() == http.MethodPost:
+		var change struct {
+			Password        string `json:"password"`
+			CurrentPassword string `json:"current_password"`
+		}
+		if err := req.decode(&change); err != nil {
+			return nil, 0, err
+		}
+		if change.Password == "" {
+			return nil, 0, errInvalidParams("password is required")
+		}
+		if req.boolParam("require_current_password") && change.CurrentPassword != rec.password {
+			return nil, 0, errIncorrectPassword()
+		}
+		rec.password = change.Password
+		return success, http.StatusOK, nil
+	}
+	return nil, 0, errNotFound()
+}
+
+func (s *Server) recentUsers(req *request) (interface{}, int, *luno.Error) {
+	var ids []string
+	for i := len(s.users) - 1; i >= 0; i-- {
+		ids = append(ids, s.users[i].user.ID)
+	}
+	start, end, page := paginate("/users", ids, req.params)
+	rv := make([]*luno.User, 0, end-start)
+	for _, id := range ids[start:end] {
+		_, rec := s.findUser(id)
+		rv = append(rv, userCopy(rec.user))
+	}
+	return &list{Type: "list", URL: "/v1/users", List: rv, Page: page}, http.StatusOK, nil
+}
+
+func (s *Server) createUser(req *request) (interface{}, int, *luno.Error) {
+	var user luno.User
+	if err := req.decode(&user); err != nil {
+		return nil, 0, err
+	}
+	if user.Email == "" && user.UserName == "" {
+		return nil, 0, errInvalidParams("email or username is required")
+	}
+	if err := s.conflict("", user.Email, user.UserName); err != nil {
+		return nil, 0, err
+	}
+	rec := &userRecord{password: user.Password}
+	user.Password = ""
+	user.Entity = luno.Entity{Type: "user", ID: newID("usr")}
+	user.URL = "/v1/users/" + user.ID
+	user.Created = s.timestamp()
+	user.Closed = luno.Time{}
+	applyName(&user, req.boolParam("auto_name"))
+	rec.user = &user
+	s.users = append(s.users, rec)
+	s.addEvent(user.ID, "User Created")
+	return userCopy(&user), http.StatusCreated, nil
+}
+
+// applyName fills in the name fields, when autoName is set the first and
+// last names are derived from the name, otherwise the name is derived from
+// the first and last names if it is missing
+func applyName(user *luno.User, autoName bool) {
+	if autoName && user.Name != "" {
+		user.FirstName, user.LastName = splitName(user.Name)
+	} else if user.Name == "" {
+		user.Name = strings.TrimSpace(user.FirstName + " " + user.LastName)
+	}
+}
+
+func (s *Server) updateUser(req *request, rec *userRecord) (interface{}, int, *luno.Error) {
+	var update struct {
+		Email     string      `json:"email"`
+		UserName  string      `json:"username"`
+		Name      string      `json:"name"`
+		FirstName string      `json:"first_name"`
+		LastName  string      `json:"last_name"`
+		Profile   interface{} `json:"profile"`
+	}
+	if err := req.decode(&update); err != nil {
+		return nil, 0, err
+	}
+	if err := s.conflict(rec.user.ID, update.Email, update.UserName); err != nil {
+		return nil, 0, err
+	}
+	user := rec.user
+	if update.Email != "" {
+		user.Email = update.Email
+	}
+	if update.UserName != "" {
+		user.UserName = update.UserName
+	}
+	if update.Name != "" {
+		user.Name = update.Name
+	}
+	if update.FirstName != "" {
+		user.FirstName = update.FirstName
+	}
+	if update.LastName != "" {
+		user.LastName = update.LastName
+	}
+	applyName(user, req.boolParam("auto_name"))
+	if update.Profile != nil {
+		if req.method() == http.MethodPut {
+			user.Profile = update.Profile
+		} else {
+			user.Profile = mergeDetails(user.Profile, update.Profile)
+		}
+	}
+	return userCopy(user), http.StatusOK, nil
+}
+
+// deleteUser permanently removes the user at index i, along with their
+// sessions and api authentication
+func (s *Server) deleteUser(i int) {
+	id := s.users[i].user.ID
+	s.users = append(s.users[:i], s.users[i+1:]...)
+	s.deleteUserSessions(id)
+	apiAuths := s.apiAuths[:0]
+	for _, apiAuth := range s.apiAuths {
+		if apiAuth.UserID != id {
+			apiAuths = append(apiAuths, apiAuth)
+		}
+	}
+	s.apiAuths = apiAuths
+}
+
+func (s *Server) login(req *request) (interface{}, int, *luno.Error) {
+	var login struct {
+		ID       string        `json:"id"`
+		Email    string        `json:"email"`
+		Username string        `json:"username"`
+		Login    string        `json:"login"`
+		Password string        `json:"password"`
+		Session  *luno.Session `json:"session"`
+	}
+	if err := req.decode(&login); err != nil {
+		return nil, 0, err
+	}
+	var rec *userRecord
+	switch {
+	case login.ID != "":
+		_, rec = s.findUser(login.ID)
+	case login.Email != "":
+		_, rec = s.findUser("email:" + login.Email)
+	case login.Username != "":
+		_, rec = s.findUser("username:" + login.Username)
+	case login.Login != "":
+		_, rec = s.findUser("email:" + login.Login)
+		if rec == nil {
+			_, rec = s.findUser("username:" + login.Login)
+		}
+	default:
+		return nil, 0, errInvalidParams("id, email, username or login is required")
+	}
+	if rec == nil {
+		return nil, 0, errUserNotFound()
+	}
+	if rec.user.IsClosed() {
+		return nil, 0, &luno.Error{Code: luno.ErrCodeUserClosed, Message: "User closed", Status: http.StatusForbidden}
+	}
+	if login.Password != rec.password {
+		s.addEvent(rec.user.ID, "Incorrect Password")
+		return nil, 0, errIncorrectPassword()
+	}
+	s.addEvent(rec.user.ID, "Correct Password")
+
+	session := login.Session
+	if session == nil {
+		session = &luno.Session{}
+	}
+	session.UserID = rec.user.ID
+	s.storeSession(session)
+	s.addEvent(rec.user.ID, "Logged In")
+
+	rv := map[string]interface{}{
+		"user":    userCopy(rec.user),
+		"session": s.sessionView(session, req.expand("user")),
+	}
+	return rv, http.StatusOK, nil
+}
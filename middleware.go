@@ -0,0 +1,89 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package luno
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Doer executes an HTTP request, *http.Client is a Doer
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// DoerFunc adapts an ordinary function to the Doer interface
+type DoerFunc func(req *http.Request) (*http.Response, error)
+
+// Do calls f(req)
+func (f DoerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware wraps a Doer to add behavior around every request.  The
+// request seen by a Middleware is fully signed, so it must not modify the
+// URL or body.  Middleware is invoked once per attempt, so retries are
+// visible to it.
+type Middleware func(next Doer) Doer
+
+// WithMiddleware adds Middleware to the client.  Middleware is applied in
+// the order provided, the first Middleware is the outermost, seeing the
+// request first and the response last.  WithMiddleware may be used more
+// than once, later Middleware is nested inside earlier Middleware.
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(c *Client) {
+		c.middleware = append(c.middleware, middleware...)
+	}
+}
+
+// chainMiddleware wraps the Doer with the Middleware, so that the first
+// Middleware is the outermost
+func chainMiddleware(doer Doer, middleware []Middleware) Doer {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		doer = middleware[i](doer)
+	}
+	return doer
+}
+
+type requestInfoKey struct{}
+
+// requestInfo describes the logical operation a request belongs to
+type requestInfo struct {
+	operation string
+	attempt   int
+	start     time.Time
+}
+
+func withRequestInfo(ctx context.Context, info requestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+func requestInfoFromContext(ctx context.Context) (requestInfo, bool) {
+	info, ok := ctx.Value(requestInfoKey{}).(requestInfo)
+	return info, ok
+}
+
+// OperationFromContext returns the name of the logical operation, such as
+// "users.get" or "sessions.access", of a request made by the Client.
+// Middleware can use this with the request context.
+func OperationFromContext(ctx context.Context) string {
+	info, _ := requestInfoFromContext(ctx)
+	return info.operation
+}
+
+// AttemptFromContext returns the attempt number, starting at 1, of a request
+// made by the Client.  Middleware can use this with the request context.
+func AttemptFromContext(ctx context.Context) int {
+	info, _ := requestInfoFromContext(ctx)
+	return info.attempt
+}
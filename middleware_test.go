@@ -0,0 +1,80 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package luno
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	var gotTrace string
+	flaky := &flakyServer{failures: 1}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotTrace = r.Header.Get("X-Trace")
+		flaky.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	var calls []string
+	recorder := func(name string) Middleware {
+		return func(next Doer) Doer {
+			return DoerFunc(func(req *http.Request) (*http.Response, error) {
+				ctx := req.Context()
+				calls = append(calls, fmt.Sprintf("%s before %s %d signed=%t", name,
+					OperationFromContext(ctx), AttemptFromContext(ctx), strings.Contains(req.URL.Opaque, "&sign=")))
+				resp, err := next.Do(req)
+				if err == nil {
+					calls = append(calls, fmt.Sprintf("%s after %d", name, resp.StatusCode))
+				}
+				return resp, err
+			})
+		}
+	}
+	tracer := func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			req.Header.Set("X-Trace", "abc")
+			return next.Do(req)
+		})
+	}
+
+	lunoClient := NewClient("key", "secret",
+		WithBaseURL(server.URL),
+		WithRetryPolicy(testRetryPolicy),
+		WithMiddleware(recorder("outer"), recorder("inner")),
+		WithMiddleware(tracer))
+
+	_, err := lunoClient.Events.Get("evt_xxxxxxxxxxxxxxxxxxxxxxxx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"outer before events.get 1 signed=true",
+		"inner before events.get 1 signed=true",
+		"inner after 503",
+		"outer after 503",
+		"outer before events.get 2 signed=true",
+		"inner before events.get 2 signed=true",
+		"inner after 200",
+		"outer after 200",
+	}
+	if !reflect.DeepEqual(expected, calls) {
+		t.Errorf("expected calls %v, got %v", expected, calls)
+	}
+	if gotTrace != "abc" {
+		t.Errorf("expected trace header abc, got '%s'", gotTrace)
+	}
+}
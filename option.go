@@ -0,0 +1,88 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package luno
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Option configures optional behavior of a Client
+type Option func(*Client)
+
+// WithBaseURL directs the client at a different Luno endpoint, for
+// example a local stand-in server in tests.  The URL may include a path
+// prefix, which is placed before the API version.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		u, err := parseBaseURL(baseURL)
+		if err != nil {
+			c.err = err
+			return
+		}
+		c.scheme = u.Scheme
+		c.host = u.Host
+		c.basePath = strings.TrimSuffix(u.Path, "/")
+	}
+}
+
+func parseBaseURL(baseURL string) (*url.URL, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base url '%s': %v", baseURL, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid base url '%s': scheme and host required", baseURL)
+	}
+	return u, nil
+}
+
+// WithAPIVersion overrides the API version, the default is "v1"
+func WithAPIVersion(version string) Option {
+	return func(c *Client) {
+		c.version = version
+	}
+}
+
+// WithHTTPClient uses the provided http.Client for all requests,
+// WithTimeout and WithTransport are applied to a copy of it
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTimeout sets the overall timeout of each HTTP request, the default
+// is 10 seconds
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithTransport sets the http.RoundTripper used to make requests, this
+// allows sharing a tuned transport or routing through a proxy
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *Client) {
+		c.transport = transport
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
@@ -0,0 +1,80 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package luno
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientOptions(t *testing.T) {
+	var gotPath, gotUserAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotUserAgent = r.Header.Get("User-Agent")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"type":"account","email":"test@example.com"}`))
+	}))
+	defer server.Close()
+
+	lunoClient := NewClient("key", "secret",
+		WithBaseURL(server.URL+"/prefix/"),
+		WithAPIVersion("v2"),
+		WithUserAgent("luno-go-test"))
+
+	account, err := lunoClient.Account.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Email != "test@example.com" {
+		t.Errorf("expected email test@example.com, got %s", account.Email)
+	}
+	if gotPath != "/prefix/v2/account" {
+		t.Errorf("expected path /prefix/v2/account, got %s", gotPath)
+	}
+	if gotUserAgent != "luno-go-test" {
+		t.Errorf("expected user agent luno-go-test, got %s", gotUserAgent)
+	}
+}
+
+func TestClientOptionsHTTPClient(t *testing.T) {
+	shared := &http.Client{Timeout: time.Minute}
+	transport := &http.Transport{}
+	lunoClient := NewClient("key", "secret",
+		WithHTTPClient(shared),
+		WithTimeout(time.Second),
+		WithTransport(transport))
+
+	if lunoClient.httpClient == shared {
+		t.Errorf("expected shared http client to be copied")
+	}
+	if shared.Timeout != time.Minute {
+		t.Errorf("expected shared http client to be unmodified, got timeout %v", shared.Timeout)
+	}
+	if lunoClient.httpClient.Timeout != time.Second {
+		t.Errorf("expected timeout 1s, got %v", lunoClient.httpClient.Timeout)
+	}
+	if lunoClient.httpClient.Transport != transport {
+		t.Errorf("expected configured transport to be used")
+	}
+}
+
+func TestClientOptionsInvalidBaseURL(t *testing.T) {
+	lunoClient := NewClient("key", "secret", WithBaseURL("not a url"))
+	_, err := lunoClient.Users.Get("usr_xxxxxxxxxxxxxxxxxxxxxxxx")
+	if err == nil || !strings.Contains(err.Error(), "invalid base url") {
+		t.Errorf("expected invalid base url error, got %v", err)
+	}
+}
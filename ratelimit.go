@@ -0,0 +1,137 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package luno
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket limiting the rate of requests, it is safe
+// for concurrent use and may be shared by several clients
+type RateLimiter struct {
+	m      sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter builds a RateLimiter allowing requestsPerSecond on
+// average, with bursts of up to burst requests
+func NewRateLimiter(requestsPerSecond float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:   requestsPerSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until a request is allowed, or the context is done
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil || l.rate <= 0 {
+		return ctx.Err()
+	}
+	l.m.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens--
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.m.Unlock()
+
+	err := sleepContext(ctx, wait)
+	if err != nil {
+		// we did not use our token, give it back
+		l.m.Lock()
+		l.tokens++
+		l.m.Unlock()
+	}
+	return err
+}
+
+// WithRateLimit limits the client to requestsPerSecond on average, with
+// bursts of up to burst requests, across all sub-clients and goroutines
+func WithRateLimit(requestsPerSecond float64, burst int) Option {
+	return func(c *Client) {
+		c.limiter = NewRateLimiter(requestsPerSecond, burst)
+	}
+}
+
+// WithRateLimiter uses the provided RateLimiter, allowing one limit to be
+// shared by several clients
+func WithRateLimiter(limiter *RateLimiter) Option {
+	return func(c *Client) {
+		c.limiter = limiter
+	}
+}
+
+// RateLimitError is returned when Luno continues to throttle requests after
+// the retry budget is exhausted, or asks us to wait longer than the
+// RetryPolicy allows
+type RateLimitError struct {
+	// RetryAfter is the wait requested by Luno, zero if none was given
+	RetryAfter time.Duration
+	// Attempts is the number of attempts made
+	Attempts int
+	// Err is the error from the final response
+	Err error
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("luno rate limit exceeded after %d attempts, retry after: %v, err: %v",
+		e.Attempts, e.RetryAfter, e.Err)
+}
+
+// Unwrap returns the error from the final response
+func (e *RateLimitError) Unwrap() error {
+	return e.Err
+}
+
+// parseRetryAfter parses a Retry-After header, which is either a number of
+// seconds or an HTTP date, ok is false if the header is missing or invalid
+func parseRetryAfter(header string, now time.Time) (time.Duration, bool) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	when, err := http.ParseTime(header)
+	if err != nil {
+		return 0, false
+	}
+	wait := when.Sub(now)
+	if wait < 0 {
+		wait = 0
+	}
+	return wait, true
+}
@@ -0,0 +1,138 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package luno
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(100, 2)
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		err := limiter.Wait(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 2 requests are allowed immediately, the other 4 wait 10ms each
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Errorf("expected rate limiter to delay requests, took %v", elapsed)
+	}
+
+	// a cancelled context stops waiting
+	limiter = NewRateLimiter(0.001, 1)
+	_ = limiter.Wait(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := limiter.Wait(ctx)
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if atomic.AddInt32(&hits, 1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"code":"rate_limited","status":429}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"type":"event","id":"evt_xxxxxxxxxxxxxxxxxxxxxxxx"}`))
+	}))
+	defer server.Close()
+
+	// throttled requests are retried, even a POST
+	lunoClient := NewClient("key", "secret", WithBaseURL(server.URL), WithRateLimit(1000, 10))
+	_, err := lunoClient.Events.Create(&Event{Name: "throttled"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hits != 2 {
+		t.Errorf("expected 2 attempts, got %d", hits)
+	}
+}
+
+func TestRateLimitError(t *testing.T) {
+	var hits int32
+	retryAfter := "0"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", retryAfter)
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"code":"rate_limited","status":429}`))
+	}))
+	defer server.Close()
+
+	lunoClient := NewClient("key", "secret", WithBaseURL(server.URL), WithRetryPolicy(testRetryPolicy))
+
+	// budget exhausted
+	_, err := lunoClient.Analytics.Users([]string{"1"})
+	var rateLimitErr *RateLimitError
+	if !errors.As(err, &rateLimitErr) {
+		t.Fatalf("expected rate limit error, got %v", err)
+	}
+	if rateLimitErr.Attempts != 3 || hits != 3 {
+		t.Errorf("expected 3 attempts, got %d (server saw %d)", rateLimitErr.Attempts, hits)
+	}
+	var lunoErr *Error
+	if !errors.As(err, &lunoErr) || lunoErr.Code != "rate_limited" {
+		t.Errorf("expected wrapped luno error rate_limited, got %v", rateLimitErr.Err)
+	}
+
+	// Retry-After longer than we are willing to wait
+	policy := testRetryPolicy
+	policy.MaxRetryAfter = time.Second
+	lunoClient = NewClient("key", "secret", WithBaseURL(server.URL), WithRetryPolicy(policy))
+	atomic.StoreInt32(&hits, 0)
+	retryAfter = "60"
+	_, err = lunoClient.Analytics.EventsTimeline(nil)
+	if !errors.As(err, &rateLimitErr) {
+		t.Fatalf("expected rate limit error, got %v", err)
+	}
+	if hits != 1 {
+		t.Errorf("expected 1 attempt, got %d", hits)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2016, 3, 28, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header string
+		wait   time.Duration
+		ok     bool
+	}{
+		{"", 0, false},
+		{"5", 5 * time.Second, true},
+		{"-1", 0, false},
+		{"Mon, 28 Mar 2016 12:00:30 GMT", 30 * time.Second, true},
+		{"Mon, 28 Mar 2016 11:00:00 GMT", 0, true},
+		{"soon", 0, false},
+	}
+	for _, test := range tests {
+		wait, ok := parseRetryAfter(test.header, now)
+		if wait != test.wait || ok != test.ok {
+			t.Errorf("header '%s' expected %v %t, got %v %t", test.header, test.wait, test.ok, wait, ok)
+		}
+	}
+}
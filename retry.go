@@ -0,0 +1,149 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package luno
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy controls how failed requests are retried.  Connection errors
+// and responses with a retryable status are retried with exponential
+// backoff.  GET, PUT and DELETE requests are idempotent and retried
+// automatically, POST and PATCH requests are only retried when
+// RetryNonIdempotent is set.  Throttled (429) requests were not processed,
+// so they are retried regardless of method, after waiting as instructed by
+// the Retry-After header.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first,
+	// values less than 1 are treated as 1 (no retries)
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, it doubles on every
+	// subsequent retry
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between attempts
+	MaxDelay time.Duration
+	// Jitter is the fraction (0 to 1) of each delay which is randomized
+	Jitter float64
+	// RetryableStatus lists the HTTP status codes which are retried
+	RetryableStatus []int
+	// RetryNonIdempotent allows POST and PATCH requests, such as
+	// Events.Create, to be retried
+	RetryNonIdempotent bool
+	// MaxRetryAfter is the longest Retry-After we are willing to wait
+	// when throttled, zero means no limit
+	MaxRetryAfter time.Duration
+}
+
+// DefaultRetryPolicy is the RetryPolicy used by a new Client
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   100 * time.Millisecond,
+	MaxDelay:    2 * time.Second,
+	Jitter:      0.5,
+	RetryableStatus: []int{
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	},
+	MaxRetryAfter: 30 * time.Second,
+}
+
+// WithRetryPolicy sets the RetryPolicy used by the client, use
+// RetryPolicy{MaxAttempts: 1} to disable retries
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retryPolicy = policy
+	}
+}
+
+// shouldRetry decides if another attempt should be made after the attempt
+// numbered attempt produced resp and err
+func (p *RetryPolicy) shouldRetry(method string, attempt int, resp *http.Response, err error) bool {
+	if attempt >= p.MaxAttempts {
+		return false
+	}
+	if !p.RetryNonIdempotent && !isIdempotent(method) {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	return p.retryableStatus(resp.StatusCode)
+}
+
+func (p *RetryPolicy) retryableStatus(status int) bool {
+	for _, retryable := range p.RetryableStatus {
+		if status == retryable {
+			return true
+		}
+	}
+	return false
+}
+
+// rateLimitDelay returns how long to wait before retrying a throttled
+// attempt, ok is false if the request should not be retried
+func (p *RetryPolicy) rateLimitDelay(attempt int, retryAfter time.Duration, hasRetryAfter bool) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts {
+		return 0, false
+	}
+	if !hasRetryAfter {
+		return p.backoff(attempt), true
+	}
+	if p.MaxRetryAfter > 0 && retryAfter > p.MaxRetryAfter {
+		return 0, false
+	}
+	return retryAfter, true
+}
+
+// backoff returns the delay to wait after the attempt numbered attempt
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		jitter := time.Duration(p.Jitter * float64(delay))
+		if jitter > 0 {
+			delay = delay - jitter + time.Duration(rand.Int63n(int64(jitter)+1))
+		}
+	}
+	return delay
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// sleepContext waits for the duration d, or until the context is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,154 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package luno
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyServer fails the first failures requests with a 503, recording the
+// body and signature of every request it sees
+type flakyServer struct {
+	m        sync.Mutex
+	failures int
+	bodies   []string
+	signs    []string
+}
+
+func (f *flakyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	f.m.Lock()
+	f.bodies = append(f.bodies, string(body))
+	f.signs = append(f.signs, r.URL.Query().Get("sign"))
+	fail := len(f.bodies) <= f.failures
+	f.m.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	if fail {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"code":"unavailable","status":503}`))
+		return
+	}
+	if r.Method == http.MethodPost {
+		w.WriteHeader(http.StatusCreated)
+	}
+	_, _ = w.Write([]byte(`{"type":"event","id":"evt_xxxxxxxxxxxxxxxxxxxxxxxx"}`))
+}
+
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts:     3,
+	BaseDelay:       time.Millisecond,
+	MaxDelay:        5 * time.Millisecond,
+	RetryableStatus: []int{http.StatusServiceUnavailable},
+}
+
+func TestRetryIdempotent(t *testing.T) {
+	flaky := &flakyServer{failures: 2}
+	server := httptest.NewServer(flaky)
+	defer server.Close()
+
+	lunoClient := NewClient("key", "secret", WithBaseURL(server.URL), WithRetryPolicy(testRetryPolicy))
+	_, err := lunoClient.Events.Get("evt_xxxxxxxxxxxxxxxxxxxxxxxx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(flaky.bodies) != 3 {
+		t.Errorf("expected 3 attempts, got %d", len(flaky.bodies))
+	}
+	for i, sign := range flaky.signs {
+		if sign == "" {
+			t.Errorf("expected attempt %d to be signed", i+1)
+		}
+	}
+}
+
+func TestRetryExhausted(t *testing.T) {
+	flaky := &flakyServer{failures: 5}
+	server := httptest.NewServer(flaky)
+	defer server.Close()
+
+	lunoClient := NewClient("key", "secret", WithBaseURL(server.URL), WithRetryPolicy(testRetryPolicy))
+	_, err := lunoClient.Events.Get("evt_xxxxxxxxxxxxxxxxxxxxxxxx")
+	if !IsErrorCode(err, "unavailable") {
+		t.Errorf("expected unavailable error, got %v", err)
+	}
+	if len(flaky.bodies) != 3 {
+		t.Errorf("expected 3 attempts, got %d", len(flaky.bodies))
+	}
+}
+
+func TestRetryNonIdempotent(t *testing.T) {
+	flaky := &flakyServer{failures: 1}
+	server := httptest.NewServer(flaky)
+	defer server.Close()
+
+	// by default a POST is not retried
+	lunoClient := NewClient("key", "secret", WithBaseURL(server.URL), WithRetryPolicy(testRetryPolicy))
+	_, err := lunoClient.Events.Create(&Event{Name: "retry"}, nil)
+	if err == nil {
+		t.Errorf("expected error creating event")
+	}
+	if len(flaky.bodies) != 1 {
+		t.Errorf("expected 1 attempt, got %d", len(flaky.bodies))
+	}
+
+	// opting in retries the POST, resending the same body
+	flaky = &flakyServer{failures: 1}
+	server2 := httptest.NewServer(flaky)
+	defer server2.Close()
+
+	policy := testRetryPolicy
+	policy.RetryNonIdempotent = true
+	lunoClient = NewClient("key", "secret", WithBaseURL(server2.URL), WithRetryPolicy(policy))
+	_, err = lunoClient.Events.Create(&Event{Name: "retry"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(flaky.bodies) != 2 {
+		t.Fatalf("expected 2 attempts, got %d", len(flaky.bodies))
+	}
+	if flaky.bodies[0] == "" || flaky.bodies[0] != flaky.bodies[1] {
+		t.Errorf("expected identical non-empty bodies, got '%s' and '%s'", flaky.bodies[0], flaky.bodies[1])
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := RetryPolicy{
+		BaseDelay: 100 * time.Millisecond,
+		MaxDelay:  time.Second,
+	}
+	expected := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}
+	for i, exp := range expected {
+		if got := policy.backoff(i + 1); got != exp {
+			t.Errorf("attempt %d expected backoff %v, got %v", i+1, exp, got)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 1; i < 10; i++ {
+		got := policy.backoff(3)
+		if got < 200*time.Millisecond || got > 400*time.Millisecond {
+			t.Errorf("expected jittered backoff between 200ms and 400ms, got %v", got)
+		}
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"time"
 )
 
 // Sessions represents a list of Session objects
@@ -49,9 +50,9 @@ type Session struct {
 	Entity
 	UserID      string      `json:"user_id,omitempty"`
 	Key         string      `json:"key,omitempty"`
-	Created     string      `json:"created,omitempty"`
-	Expires     string      `json:"expires,omitempty"`
-	LastAccess  string      `json:"last_access,omitempty"`
+	Created     Time        `json:"created,omitzero"`
+	Expires     Time        `json:"expires,omitzero"`
+	LastAccess  Time        `json:"last_access,omitzero"`
 	AccessCount int         `json:"access_count,omitempty"`
 	IP          string      `json:"ip,omitempty"`
 	UserAgent   string      `json:"user_agent,omitempty"`
@@ -59,6 +60,11 @@ type Session struct {
 	User        *User       `json:"user,omitempty"`
 }
 
+// IsExpired returns true if the Session has an expiry which is not after now
+func (s *Session) IsExpired(now time.Time) bool {
+	return !s.Expires.IsZero() && !now.Before(s.Expires.Time)
+}
+
 // MarshalForUpdate exports only the Session fields suitable for an update operation
 func (s *Session) MarshalForUpdate(includeKey bool) ([]byte, error) {
 	tmp := map[string]interface{}{}
@@ -68,7 +74,7 @@ func (s *Session) MarshalForUpdate(includeKey bool) ([]byte, error) {
 	if s.UserID != "" {
 		tmp["user_id"] = s.UserID
 	}
-	if s.Expires != "" {
+	if !s.Expires.IsZero() {
 		tmp["expires"] = s.Expires
 	}
 	if s.IP != "" {
@@ -103,7 +109,7 @@ type Login struct {
 	ID       string   `json:"id,omitempty"`
 	Email    string   `json:"email,omitempty"`
 	Username string   `json:"username,omitempty"`
-	Login    string   `json:"username,omitempty"`
+	Login    string   `json:"login,omitempty"`
 	Password string   `json:"password,omitempty"`
 	Session  *Session `json:"session,omitempty"`
 }
@@ -112,7 +118,7 @@ type Login struct {
 func (l *Login) MarshalJSON() ([]byte, error) {
 	session := make(map[string]interface{})
 	if l.Session != nil {
-		if l.Session.Expires != "" {
+		if !l.Session.Expires.IsZero() {
 			session["expires"] = l.Session.Expires
 		}
 		if l.Session.IP != "" {
@@ -0,0 +1,253 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package luno
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SessionCacheConfig controls how long a SessionCache keeps sessions
+type SessionCacheConfig struct {
+	// TTL is how long a valid session is cached, it is never cached beyond
+	// its Expires time, zero disables caching of valid sessions
+	TTL time.Duration
+	// NegativeTTL is how long a session which was not found, or has
+	// expired, is cached, zero disables negative caching
+	NegativeTTL time.Duration
+	// MaxEntries limits the number of cached results, the least recently
+	// used are evicted first, zero means no limit
+	MaxEntries int
+	// AccessInterval is how often Sessions.Access is still sent to Luno for
+	// a cached session, keeping AccessCount and LastAccess roughly
+	// accurate, zero means never while the session is cached
+	AccessInterval time.Duration
+	// Now returns the current time, nil means time.Now
+	Now func() time.Time
+}
+
+// DefaultSessionCacheConfig is a reasonable SessionCacheConfig for a web
+// application authenticating every request
+var DefaultSessionCacheConfig = SessionCacheConfig{
+	TTL:            time.Minute,
+	NegativeTTL:    10 * time.Second,
+	MaxEntries:     10000,
+	AccessInterval: 5 * time.Minute,
+}
+
+// SessionCache caches the results of Sessions.Access and Sessions.Get, it is
+// safe for concurrent use and may be shared by several clients using the
+// same Luno account.  Cached results are invalidated by Sessions.Update,
+// Sessions.Delete, Users.DeleteSessions, Users.Deactivate and Users.Delete
+// made through a client using the cache.  A cached Sessions.Access does not
+// update the IP or UserAgent of the session.
+type SessionCache struct {
+	config SessionCacheConfig
+
+	m       sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+type sessionCacheEntry struct {
+	cacheKey string
+	session  *Session
+	err      error
+	expires  time.Time
+	accessed time.Time
+}
+
+// NewSessionCache builds a new, empty, SessionCache
+func NewSessionCache(config SessionCacheConfig) *SessionCache {
+	return &SessionCache{
+		config:  config,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// WithSessionCache caches the results of Sessions.Access and Sessions.Get
+// in the provided SessionCache
+func WithSessionCache(cache *SessionCache) Option {
+	return func(c *Client) {
+		c.sessionCache = cache
+	}
+}
+
+// Len returns the number of cached results
+func (sc *SessionCache) Len() int {
+	sc.m.Lock()
+	defer sc.m.Unlock()
+	return sc.lru.Len()
+}
+
+// Purge removes all cached results
+func (sc *SessionCache) Purge() {
+	sc.m.Lock()
+	defer sc.m.Unlock()
+	sc.lru.Init()
+	sc.entries = make(map[string]*list.Element)
+}
+
+// InvalidateSession removes cached results for the session with the
+// provided ID, including a cached not found result
+func (sc *SessionCache) InvalidateSession(id string) {
+	sc.invalidate(func(ent *sessionCacheEntry) bool {
+		return ent.cacheKey == sessionCacheIDKey(id) ||
+			(ent.session != nil && ent.session.ID == id)
+	})
+}
+
+// InvalidateUser removes cached results for all sessions of the user with
+// the provided ID
+func (sc *SessionCache) InvalidateUser(userID string) {
+	sc.invalidate(func(ent *sessionCacheEntry) bool {
+		return ent.session != nil && ent.session.UserID == userID
+	})
+}
+
+func (sc *SessionCache) invalidate(match func(*sessionCacheEntry) bool) {
+	if sc == nil {
+		return
+	}
+	sc.m.Lock()
+	defer sc.m.Unlock()
+	for elem := sc.lru.Front(); elem != nil; {
+		next := elem.Next()
+		ent := elem.Value.(*sessionCacheEntry)
+		if match(ent) {
+			sc.lru.Remove(elem)
+			delete(sc.entries, ent.cacheKey)
+		}
+		elem = next
+	}
+}
+
+func (sc *SessionCache) now() time.Time {
+	if sc.config.Now != nil {
+		return sc.config.Now()
+	}
+	return time.Now()
+}
+
+func sessionCacheIDKey(id string) string {
+	return "id:" + id
+}
+
+func sessionCacheAccessKey(key string, expand []string) string {
+	return "key:" + key + "|" + strings.Join(expand, ",")
+}
+
+// load returns the cached result for cacheKey, or calls fetch and caches its
+// result.  When access is true, fetch is called for a cached session once
+// AccessInterval has passed, if that fails for reasons other than the
+// session being invalid, the cached session is returned.
+func (sc *SessionCache) load(ctx context.Context, cacheKey string, access bool, fetch func(ctx context.Context) (*Session, error)) (*Session, error) {
+	now := sc.now()
+	cached, ok := sc.lookup(cacheKey, now)
+	if ok {
+		if cached.err != nil {
+			return nil, cached.err
+		}
+		if !access || sc.config.AccessInterval <= 0 || now.Sub(cached.accessed) < sc.config.AccessInterval {
+			return copySession(cached.session), nil
+		}
+	}
+	session, err := fetch(ctx)
+	if err != nil {
+		if sessionGone(err) {
+			sc.store(cacheKey, nil, err, now)
+		} else if ok && ctx.Err() == nil {
+			return copySession(cached.session), nil
+		}
+		return nil, err
+	}
+	sc.store(cacheKey, session, nil, now)
+	return copySession(session), nil
+}
+
+// lookup returns a copy of the unexpired entry for cacheKey
+func (sc *SessionCache) lookup(cacheKey string, now time.Time) (sessionCacheEntry, bool) {
+	sc.m.Lock()
+	defer sc.m.Unlock()
+	elem, ok := sc.entries[cacheKey]
+	if !ok {
+		return sessionCacheEntry{}, false
+	}
+	ent := elem.Value.(*sessionCacheEntry)
+	if !now.Before(ent.expires) {
+		sc.lru.Remove(elem)
+		delete(sc.entries, cacheKey)
+		return sessionCacheEntry{}, false
+	}
+	sc.lru.MoveToFront(elem)
+	return *ent, true
+}
+
+// store caches either a session or an error for cacheKey, evicting the least
+// recently used entries if the cache is full
+func (sc *SessionCache) store(cacheKey string, session *Session, err error, now time.Time) {
+	ent := &sessionCacheEntry{
+		cacheKey: cacheKey,
+		session:  copySession(session),
+		err:      err,
+		accessed: now,
+	}
+	if err != nil {
+		ent.expires = now.Add(sc.config.NegativeTTL)
+	} else {
+		ent.expires = now.Add(sc.config.TTL)
+		if !session.Expires.IsZero() && session.Expires.Before(ent.expires) {
+			ent.expires = session.Expires.Time
+		}
+	}
+
+	sc.m.Lock()
+	defer sc.m.Unlock()
+	if elem, ok := sc.entries[cacheKey]; ok {
+		sc.lru.Remove(elem)
+		delete(sc.entries, cacheKey)
+	}
+	if !now.Before(ent.expires) {
+		return
+	}
+	sc.entries[cacheKey] = sc.lru.PushFront(ent)
+	for sc.config.MaxEntries > 0 && sc.lru.Len() > sc.config.MaxEntries {
+		oldest := sc.lru.Back()
+		sc.lru.Remove(oldest)
+		delete(sc.entries, oldest.Value.(*sessionCacheEntry).cacheKey)
+	}
+}
+
+// sessionGone checks if err means the session does not exist, or is no
+// longer valid, these results may be cached
+func sessionGone(err error) bool {
+	return errors.Is(err, ErrSessionNotFound) || errors.Is(err, ErrSessionExpired)
+}
+
+// copySession returns a copy of session, so that callers modifying the
+// returned session do not modify the cache
+func copySession(session *Session) *Session {
+	if session == nil {
+		return nil
+	}
+	rv := *session
+	if session.User != nil {
+		user := *session.User
+		rv.User = &user
+	}
+	return &rv
+}
@@ -0,0 +1,244 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package luno_test
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mschoch/luno-go"
+	"github.com/mschoch/luno-go/lunotest"
+)
+
+// opCounter counts the requests made for each operation
+type opCounter struct {
+	m      sync.Mutex
+	counts map[string]int
+}
+
+func (o *opCounter) middleware(next luno.Doer) luno.Doer {
+	return luno.DoerFunc(func(req *http.Request) (*http.Response, error) {
+		o.m.Lock()
+		o.counts[luno.OperationFromContext(req.Context())]++
+		o.m.Unlock()
+		return next.Do(req)
+	})
+}
+
+func (o *opCounter) count(op string) int {
+	o.m.Lock()
+	defer o.m.Unlock()
+	return o.counts[op]
+}
+
+// fakeClock is a clock shared by the cache and the server, advanced by
+// tests instead of sleeping
+type fakeClock struct {
+	m   sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newCachingClient(t *testing.T, config luno.SessionCacheConfig) (*luno.Client, *luno.SessionCache, *opCounter) {
+	lunoClient, cache, counter, _ := newClockedCachingClient(t, config)
+	return lunoClient, cache, counter
+}
+
+func newClockedCachingClient(t *testing.T, config luno.SessionCacheConfig) (*luno.Client, *luno.SessionCache, *opCounter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2016, 1, 2, 15, 4, 5, 0, time.UTC)}
+	config.Now = clock.Now
+	counter := &opCounter{counts: make(map[string]int)}
+	cache := luno.NewSessionCache(config)
+	lunoClient, server := lunotest.NewClient(t, luno.WithSessionCache(cache), luno.WithMiddleware(counter.middleware))
+	server.Now = clock.Now
+	return lunoClient, cache, counter, clock
+}
+
+func newUserSession(t *testing.T, lunoClient *luno.Client, session *luno.Session) (*luno.User, *luno.Session) {
+	user, err := lunoClient.Users.Create(&luno.User{Email: "cache@example.com", Password: "secret123"}, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, session, err = lunoClient.Users.LoginWithEmail(user.Email, "secret123", nil, session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user, session
+}
+
+func TestSessionCacheAccess(t *testing.T) {
+	config := luno.DefaultSessionCacheConfig
+	config.AccessInterval = 0
+	lunoClient, cache, counter := newCachingClient(t, config)
+	user, session := newUserSession(t, lunoClient, nil)
+
+	for i := 0; i < 3; i++ {
+		accessed, err := lunoClient.Sessions.Access(&luno.Session{Key: session.Key}, []string{"user"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if accessed.ID != session.ID || accessed.User == nil || accessed.User.ID != user.ID {
+			t.Errorf("expected session %s with user %s, got %+v", session.ID, user.ID, accessed)
+		}
+		// modifying the result must not modify the cache
+		accessed.User.Email = "changed@example.com"
+	}
+	if n := counter.count("sessions.access"); n != 1 {
+		t.Errorf("expected 1 access request, got %d", n)
+	}
+
+	// without expand is cached separately
+	accessed, err := lunoClient.Sessions.Access(&luno.Session{Key: session.Key}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accessed.User != nil {
+		t.Errorf("expected user not to be expanded")
+	}
+	if n := counter.count("sessions.access"); n != 2 {
+		t.Errorf("expected 2 access requests, got %d", n)
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err = lunoClient.Sessions.Get(session.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := counter.count("sessions.get"); n != 1 {
+		t.Errorf("expected 1 get request, got %d", n)
+	}
+	if cache.Len() != 3 {
+		t.Errorf("expected 3 cached results, got %d", cache.Len())
+	}
+
+	// deleting the session invalidates it
+	err = lunoClient.Sessions.Delete(session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = lunoClient.Sessions.Access(&luno.Session{Key: session.Key}, []string{"user"})
+	if !luno.IsErrorCode(err, luno.ErrCodeSessionNotFound) {
+		t.Errorf("expected session not found after delete, got %v", err)
+	}
+	if n := counter.count("sessions.access"); n != 3 {
+		t.Errorf("expected 3 access requests, got %d", n)
+	}
+
+	// not found is cached
+	_, err = lunoClient.Sessions.Access(&luno.Session{Key: session.Key}, []string{"user"})
+	if !luno.IsErrorCode(err, luno.ErrCodeSessionNotFound) {
+		t.Errorf("expected cached session not found, got %v", err)
+	}
+	if n := counter.count("sessions.access"); n != 3 {
+		t.Errorf("expected not found to be cached, got %d access requests", n)
+	}
+}
+
+func TestSessionCacheInvalidateUser(t *testing.T) {
+	lunoClient, cache, counter := newCachingClient(t, luno.DefaultSessionCacheConfig)
+	user, session := newUserSession(t, lunoClient, nil)
+
+	_, err := lunoClient.Sessions.Access(&luno.Session{Key: session.Key}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = lunoClient.Users.DeleteSessions(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cache.Len() != 0 {
+		t.Errorf("expected users sessions to be invalidated, got %d cached", cache.Len())
+	}
+	_, err = lunoClient.Sessions.Access(&luno.Session{Key: session.Key}, nil)
+	if !luno.IsErrorCode(err, luno.ErrCodeSessionNotFound) {
+		t.Errorf("expected session not found, got %v", err)
+	}
+	if n := counter.count("sessions.access"); n != 2 {
+		t.Errorf("expected 2 access requests, got %d", n)
+	}
+}
+
+func TestSessionCacheExpiry(t *testing.T) {
+	config := luno.SessionCacheConfig{
+		TTL:            time.Hour,
+		MaxEntries:     1,
+		AccessInterval: time.Minute,
+	}
+	lunoClient, cache, counter, clock := newClockedCachingClient(t, config)
+	_, session := newUserSession(t, lunoClient, nil)
+
+	_, err := lunoClient.Sessions.Access(&luno.Session{Key: session.Key}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = lunoClient.Sessions.Access(&luno.Session{Key: session.Key}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := counter.count("sessions.access"); n != 1 {
+		t.Errorf("expected 1 access request, got %d", n)
+	}
+
+	// after the access interval, luno is accessed again
+	clock.Advance(2 * time.Minute)
+	accessed, err := lunoClient.Sessions.Access(&luno.Session{Key: session.Key}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := counter.count("sessions.access"); n != 2 {
+		t.Errorf("expected access after interval, got %d access requests", n)
+	}
+	if accessed.AccessCount != 2 {
+		t.Errorf("expected access count 2, got %d", accessed.AccessCount)
+	}
+
+	// the cache is limited to 1 entry
+	_, err = lunoClient.Sessions.Get(session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cache.Len() != 1 {
+		t.Errorf("expected 1 cached result, got %d", cache.Len())
+	}
+}
+
+func TestSessionCacheBoundedByExpires(t *testing.T) {
+	lunoClient, _, counter, clock := newClockedCachingClient(t, luno.DefaultSessionCacheConfig)
+	_, session := newUserSession(t, lunoClient, &luno.Session{Expires: luno.NewTime(clock.Now().Add(10 * time.Second))})
+
+	_, err := lunoClient.Sessions.Access(&luno.Session{Key: session.Key}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(20 * time.Second)
+	_, err = lunoClient.Sessions.Access(&luno.Session{Key: session.Key}, nil)
+	if !luno.IsErrorCode(err, luno.ErrCodeSessionExpired) {
+		t.Errorf("expected session expired, got %v", err)
+	}
+	if n := counter.count("sessions.access"); n != 2 {
+		t.Errorf("expected 2 access requests, got %d", n)
+	}
+}
@@ -9,20 +9,20 @@
 //  express or implied. See the License for the specific language
 //  governing permissions and limitations under the License.
 
-package luno
+package luno_test
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/mschoch/luno-go"
+)
 
 func TestSessionCrud(t *testing.T) {
-	apiKey, secretKey, err := lunoKeysFromEnv()
-	if err != nil {
-		t.Fatal(err)
-	}
-	lunoClient := NewClient(apiKey, secretKey)
+	lunoClient := newTestClient(t)
 
 	// try to get a non-existent session
-	_, err = lunoClient.Sessions.Get("sess_xxxxxxxxxxxxxxxxxxxxxxxx")
-	if !IsErrorCode(err, ErrCodeSessionNotFound) {
+	_, err := lunoClient.Sessions.Get("sess_xxxxxxxxxxxxxxxxxxxxxxxx")
+	if !luno.IsErrorCode(err, luno.ErrCodeSessionNotFound) {
 		t.Errorf("expected error session not found, got %v", err)
 	}
 
@@ -36,7 +36,7 @@ func TestSessionCrud(t *testing.T) {
 	}
 
 	// try to create an anonymous session
-	anonSession := &Session{
+	anonSession := &luno.Session{
 		UserID: "",
 		Details: map[string]interface{}{
 			"robot": "yes",
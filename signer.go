@@ -0,0 +1,207 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package luno
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultMaxSkew is how far the timestamp of a signed request may be from
+// the current time, when verified by a Verifier with no MaxSkew
+const DefaultMaxSkew = 5 * time.Minute
+
+// signParam is the query parameter holding the signature, it is always the
+// last parameter
+const signParam = "&sign="
+
+// Signer signs requests with an API key and secret, in the form used by the
+// Luno API.  The key and an RFC3339 timestamp are added to the query
+// string, followed finally by the sign parameter.  The signature is the hex
+// HMAC-SHA512, with the secret, of "METHOD:URI" or "METHOD:URI:body", where
+// URI is the path and query string up to the sign parameter.
+type Signer struct {
+	Key    string
+	Secret string
+	// Now returns the time used for the timestamp, nil means time.Now
+	Now func() time.Time
+}
+
+// NewSigner builds a new Signer with the provided API key and secret key
+func NewSigner(key, secret string) *Signer {
+	return &Signer{Key: key, Secret: secret}
+}
+
+// Signature returns the signature of a request with the provided method,
+// URI and body
+func (s *Signer) Signature(method, uri string, body []byte) string {
+	return signature(s.Secret, method, uri, body)
+}
+
+func signature(secret, method, uri string, body []byte) string {
+	msg := method + ":" + uri
+	if len(body) > 0 {
+		msg += ":" + string(body)
+	}
+	mac := hmac.New(sha512.New, []byte(secret))
+	_, _ = mac.Write([]byte(msg))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignRequest adds the key, timestamp and signature to the query string of
+// req, replacing any already present.  The body is read using GetBody if
+// set, otherwise it is read and replaced.  A URL with Opaque set is signed
+// as is, otherwise the escaped path is used.
+func (s *Signer) SignRequest(req *http.Request) error {
+	body, err := RequestBody(req)
+	if err != nil {
+		return fmt.Errorf("error reading request body: %v", err)
+	}
+	path, rawQuery := req.URL.EscapedPath(), req.URL.RawQuery
+	if req.URL.Opaque != "" {
+		path, rawQuery, _ = strings.Cut(req.URL.Opaque, "?")
+	}
+	params, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return fmt.Errorf("error parsing request query: %v", err)
+	}
+	params.Del("sign")
+	params.Set("key", s.Key)
+	params.Set("timestamp", s.now().Format(time.RFC3339))
+	query := params.Encode()
+	sign := s.Signature(req.Method, path+"?"+query, body)
+	if req.URL.Opaque != "" {
+		req.URL.Opaque = path + "?" + query + signParam + sign
+	} else {
+		req.URL.RawQuery = query + signParam + sign
+	}
+	return nil
+}
+
+func (s *Signer) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+// RequestBody returns the body of req, leaving it readable, so that
+// signers, verifiers and recorders can read it before it is sent
+func RequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = body.Close() }()
+		return ioutil.ReadAll(body)
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	return body, nil
+}
+
+// Verifier checks the requests received by a server were signed as by a
+// Signer, see Signer for the details of the scheme
+type Verifier struct {
+	// MaxSkew is how far the timestamp of a request may be from the
+	// current time, older requests are rejected to prevent replays, zero
+	// means DefaultMaxSkew
+	MaxSkew time.Duration
+	// Now returns the current time, nil means time.Now
+	Now func() time.Time
+}
+
+// SignedRequest is a request with a fresh timestamp, which can be verified
+// once the secret for its Key is known
+type SignedRequest struct {
+	Key       string
+	Timestamp time.Time
+	Signature string
+
+	method string
+	uri    string
+	body   []byte
+}
+
+// Parse extracts the key, timestamp and signature of req, checking the
+// timestamp is within MaxSkew.  The body must be provided, as the body of
+// req is not read.  Errors are *Error with the invalid_api_key,
+// invalid_timestamp or invalid_signature codes.
+func (v *Verifier) Parse(req *http.Request, body []byte) (*SignedRequest, error) {
+	uri := req.RequestURI
+	if uri == "" {
+		uri = req.URL.RequestURI()
+	}
+	params := req.URL.Query()
+	key := params.Get("key")
+	if key == "" {
+		return nil, &Error{Code: ErrCodeInvalidAPIKey, Message: "Missing API key", Status: http.StatusUnauthorized}
+	}
+	timestamp, err := time.Parse(time.RFC3339, params.Get("timestamp"))
+	if err != nil {
+		return nil, &Error{Code: ErrCodeInvalidTimestamp, Message: "Invalid timestamp", Status: http.StatusUnauthorized}
+	}
+	maxSkew := v.MaxSkew
+	if maxSkew == 0 {
+		maxSkew = DefaultMaxSkew
+	}
+	now := time.Now()
+	if v.Now != nil {
+		now = v.Now()
+	}
+	skew := now.Sub(timestamp)
+	if skew < -maxSkew || skew > maxSkew {
+		return nil, &Error{Code: ErrCodeInvalidTimestamp, Message: "Timestamp outside of allowed window", Status: http.StatusUnauthorized}
+	}
+	signPos := strings.LastIndex(uri, signParam)
+	if signPos < 0 {
+		return nil, &Error{Code: ErrCodeInvalidSignature, Message: "Missing signature", Status: http.StatusUnauthorized}
+	}
+	return &SignedRequest{
+		Key:       key,
+		Timestamp: timestamp,
+		Signature: uri[signPos+len(signParam):],
+		method:    req.Method,
+		uri:       uri[:signPos],
+		body:      body,
+	}, nil
+}
+
+// Verify checks the request was signed with secret, using a constant time
+// comparison.  The error is an *Error with the invalid_signature code.
+func (r *SignedRequest) Verify(secret string) error {
+	expected := signature(secret, r.method, r.uri, r.body)
+	if !hmac.Equal([]byte(expected), []byte(r.Signature)) {
+		return &Error{Code: ErrCodeInvalidSignature, Message: "Invalid signature", Status: http.StatusUnauthorized}
+	}
+	return nil
+}
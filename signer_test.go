@@ -0,0 +1,157 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package luno
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var signerTestTime = time.Date(2016, 1, 2, 15, 4, 5, 0, time.UTC)
+
+const (
+	signerTestGetSignature  = "f0ddb8efcd629b550596eafb1fc5624d7477df6f81f1ed7945e09d8cb9bdff87b89d617a95c4fe084f7aa897195621aa52b597deb1d1619120f769f321e6643c"
+	signerTestPostSignature = "374b16070801c033c0f73771e2e325403ba352b2dba04bba8e078c17c4fdf0866acdfdf78ee5c4d8922f6bcb32e8330f5209a3b200b4f08420ef786ab44e6230"
+)
+
+func testSigner() *Signer {
+	signer := NewSigner("test-key", "test-secret")
+	signer.Now = func() time.Time { return signerTestTime }
+	return signer
+}
+
+func TestSignatureVectors(t *testing.T) {
+	signer := testSigner()
+	tests := []struct {
+		method string
+		uri    string
+		body   string
+		sign   string
+	}{
+		{
+			method: http.MethodGet,
+			uri:    "/v1/users?key=test-key&limit=10&timestamp=2016-01-02T15%3A04%3A05Z",
+			sign:   signerTestGetSignature,
+		},
+		{
+			method: http.MethodPost,
+			uri:    "/v1/events?key=test-key&timestamp=2016-01-02T15%3A04%3A05Z",
+			body:   `{"name":"Test Event"}`,
+			sign:   signerTestPostSignature,
+		},
+	}
+	for _, test := range tests {
+		got := signer.Signature(test.method, test.uri, []byte(test.body))
+		if got != test.sign {
+			t.Errorf("expected signature %s for %s %s, got %s", test.sign, test.method, test.uri, got)
+		}
+	}
+}
+
+func TestSignRequest(t *testing.T) {
+	signer := testSigner()
+
+	req, err := http.NewRequest(http.MethodGet, "https://api.luno.io/v1/users?limit=10&sign=stale", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = signer.SignRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "key=test-key&limit=10&timestamp=2016-01-02T15%3A04%3A05Z&sign=" + signerTestGetSignature
+	if req.URL.RawQuery != expected {
+		t.Errorf("expected query %s, got %s", expected, req.URL.RawQuery)
+	}
+
+	// opaque urls, as used by the Client, and bodies without GetBody
+	req, err = http.NewRequest(http.MethodPost, "https://api.luno.io", strings.NewReader(`{"name":"Test Event"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.URL.Opaque = "/v1/events?"
+	req.GetBody = nil
+	err = signer.SignRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "/v1/events?key=test-key&timestamp=2016-01-02T15%3A04%3A05Z&sign=" + signerTestPostSignature
+	if req.URL.Opaque != expected {
+		t.Errorf("expected opaque %s, got %s", expected, req.URL.Opaque)
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil || string(body) != `{"name":"Test Event"}` {
+		t.Errorf("expected body to remain readable, got %q %v", body, err)
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	signer := testSigner()
+	body := `{"name":"Test Event"}`
+	out, err := http.NewRequest(http.MethodPost, "https://example.com/v1/events?user_id=usr_1", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = signer.SignRequest(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := httptest.NewRequest(out.Method, out.URL.RequestURI(), strings.NewReader(body))
+
+	verifier := &Verifier{Now: func() time.Time { return signerTestTime.Add(time.Minute) }}
+	signed, err := verifier.Parse(in, []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signed.Key != "test-key" || !signed.Timestamp.Equal(signerTestTime) {
+		t.Errorf("unexpected signed request %+v", signed)
+	}
+	err = signed.Verify("test-secret")
+	if err != nil {
+		t.Errorf("expected signature to verify, got %v", err)
+	}
+	err = signed.Verify("wrong-secret")
+	if !IsErrorCode(err, ErrCodeInvalidSignature) {
+		t.Errorf("expected invalid signature, got %v", err)
+	}
+
+	// tampered body
+	signed, err = verifier.Parse(in, []byte(`{"name":"Other Event"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsErrorCode(signed.Verify("test-secret"), ErrCodeInvalidSignature) {
+		t.Errorf("expected tampered body to fail verification")
+	}
+
+	// outside the skew window
+	verifier.Now = func() time.Time { return signerTestTime.Add(DefaultMaxSkew + time.Second) }
+	_, err = verifier.Parse(in, []byte(body))
+	if !IsErrorCode(err, ErrCodeInvalidTimestamp) {
+		t.Errorf("expected invalid timestamp, got %v", err)
+	}
+	verifier.MaxSkew = time.Hour
+	_, err = verifier.Parse(in, []byte(body))
+	if err != nil {
+		t.Errorf("expected timestamp within configured skew, got %v", err)
+	}
+
+	// unsigned
+	_, err = verifier.Parse(httptest.NewRequest(http.MethodGet, "/v1/events", nil), nil)
+	if !IsErrorCode(err, ErrCodeInvalidAPIKey) {
+		t.Errorf("expected invalid api key, got %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package luno
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// TimeFormat is the format of timestamps used by Luno
+const TimeFormat = time.RFC3339Nano
+
+// Time is a timestamp as used by Luno.  It marshals to and from JSON in
+// TimeFormat, the zero Time marshals to null and is omitted by fields
+// tagged omitzero, null and "" unmarshal to the zero Time.
+type Time struct {
+	time.Time
+}
+
+// NewTime returns a Time for the provided time.Time
+func NewTime(t time.Time) Time {
+	return Time{Time: t}
+}
+
+// MarshalJSON converts a Time to JSON
+func (t Time) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(t.UTC().Format(TimeFormat))
+}
+
+// UnmarshalJSON parses a Time from JSON
+func (t *Time) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		t.Time = time.Time{}
+		return nil
+	}
+	var s string
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return fmt.Errorf("error parsing luno time json: '%s' err: %v", data, err)
+	}
+	if s == "" {
+		t.Time = time.Time{}
+		return nil
+	}
+	parsed, err := time.Parse(TimeFormat, s)
+	if err != nil {
+		return fmt.Errorf("error parsing luno time: '%s' err: %v", s, err)
+	}
+	t.Time = parsed
+	return nil
+}
+
+// String formats the Time in TimeFormat, or returns "" for the zero Time
+func (t Time) String() string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(TimeFormat)
+}
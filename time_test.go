@@ -0,0 +1,84 @@
+//  Copyright (c) 2016 Marty Schoch
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the
+//  License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an "AS
+//  IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+//  express or implied. See the License for the specific language
+//  governing permissions and limitations under the License.
+
+package luno
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTimeJSON(t *testing.T) {
+	var session Session
+	err := json.Unmarshal([]byte(`{"created":"2016-03-28T12:30:00Z","expires":"2016-04-27T12:30:00.5-04:00","last_access":null}`), &session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := time.Date(2016, 3, 28, 12, 30, 0, 0, time.UTC)
+	if !session.Created.Equal(expected) {
+		t.Errorf("expected created %v, got %v", expected, session.Created)
+	}
+	expected = time.Date(2016, 4, 27, 16, 30, 0, 500000000, time.UTC)
+	if !session.Expires.Equal(expected) {
+		t.Errorf("expected expires %v, got %v", expected, session.Expires)
+	}
+	if !session.LastAccess.IsZero() {
+		t.Errorf("expected zero last access, got %v", session.LastAccess)
+	}
+
+	// zero times are omitted, others are written in UTC
+	sessionJSON, err := json.Marshal(&Session{Expires: NewTime(time.Date(2016, 3, 28, 8, 0, 0, 0, time.FixedZone("EDT", -4*3600)))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(sessionJSON) != `{"expires":"2016-03-28T12:00:00Z"}` {
+		t.Errorf("expected only expires in UTC, got %s", sessionJSON)
+	}
+
+	var user User
+	err = json.Unmarshal([]byte(`{"created":"not a time"}`), &user)
+	if err == nil {
+		t.Errorf("expected error parsing invalid time")
+	}
+	err = json.Unmarshal([]byte(`{"closed":""}`), &user)
+	if err != nil || user.IsClosed() {
+		t.Errorf("expected empty closed to be zero, got %v %v", user.Closed, err)
+	}
+}
+
+func TestTimeHelpers(t *testing.T) {
+	now := time.Date(2016, 3, 28, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		expires Time
+		expired bool
+	}{
+		{Time{}, false},
+		{NewTime(now.Add(time.Second)), false},
+		{NewTime(now), true},
+		{NewTime(now.Add(-time.Second)), true},
+	}
+	for _, test := range tests {
+		session := &Session{Expires: test.expires}
+		if session.IsExpired(now) != test.expired {
+			t.Errorf("expected session expiring %v to be expired %t at %v", test.expires, test.expired, now)
+		}
+	}
+
+	user := &User{}
+	if user.IsClosed() {
+		t.Errorf("expected user without closed time to be open")
+	}
+	user.Closed = NewTime(now)
+	if !user.IsClosed() {
+		t.Errorf("expected user with closed time to be closed")
+	}
+}
@@ -47,8 +47,8 @@ type User struct {
 	Name      string      `json:"name,omitempty"`
 	FirstName string      `json:"first_name,omitempty"`
 	LastName  string      `json:"last_name,omitempty"`
-	Created   string      `json:"created,omitempty"`
-	Closed    string      `json:"closed,omitempty"`
+	Created   Time        `json:"created,omitzero"`
+	Closed    Time        `json:"closed,omitzero"`
 	Password  string      `json:"password,omitempty"`
 	Profile   interface{} `json:"profile,omitempty"`
 }
@@ -85,6 +85,11 @@ func ParseLogin(resp *http.Response) (*User, *Session, error) {
 	return rv.User, rv.Session, nil
 }
 
+// IsClosed returns true if the User has been deactivated
+func (u *User) IsClosed() bool {
+	return !u.Closed.IsZero()
+}
+
 // MarshalForUpdate exports only the User fields suitable for an update operation
 func (u *User) MarshalForUpdate() ([]byte, error) {
 	tmp := map[string]interface{}{
@@ -9,16 +9,18 @@
 //  express or implied. See the License for the specific language
 //  governing permissions and limitations under the License.
 
-package luno
+package luno_test
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mschoch/luno-go"
+)
 
 func TestUserCrud(t *testing.T) {
-	apiKey, secretKey, err := lunoKeysFromEnv()
-	if err != nil {
-		t.Fatal(err)
-	}
-	lunoClient := NewClient(apiKey, secretKey)
+	lunoClient := newTestClient(t)
 
 	// get the list of users, expecting 0
 	recentUsers, err := lunoClient.Users.Recent(nil, nil)
@@ -30,7 +32,7 @@ func TestUserCrud(t *testing.T) {
 	}
 
 	// now create a user
-	newUser := &User{
+	newUser := &luno.User{
 		Name:     "Bozo Clown",
 		Email:    "bozo@clown.com",
 		Password: "h8clownz",
@@ -45,7 +47,7 @@ func TestUserCrud(t *testing.T) {
 
 	// validate wrong password
 	err = lunoClient.Users.ValidatePassword(createdUser.ID, "wrongpassword")
-	if !IsErrorCode(err, ErrCodeIncorrectPassword) {
+	if !luno.IsErrorCode(err, luno.ErrCodeIncorrectPassword) {
 		t.Errorf("expected incorrect password, got %v", err)
 	}
 
@@ -167,7 +169,7 @@ func TestUserCrud(t *testing.T) {
 		t.Errorf("expected username to match, got %s != %s", findUserbyEmail.Name, findUserbyID.Name)
 	}
 	// check that its actually deactivated
-	if findUserbyEmail.Closed == "" {
+	if !findUserbyEmail.IsClosed() {
 		t.Fatalf("expected deactivated user to have non-empty closed")
 	}
 
@@ -196,7 +198,7 @@ func TestUserCrud(t *testing.T) {
 		t.Errorf("expected username to match, got %s != %s", findUserbyEmail.Name, findUserbyID.Name)
 	}
 	// check that its actually deactivated
-	if findUserbyEmail.Closed != "" {
+	if findUserbyEmail.IsClosed() {
 		t.Fatalf("expected deactivated user to have empty closed")
 	}
 
@@ -217,14 +219,10 @@ func TestUserCrud(t *testing.T) {
 }
 
 func TestUserLogin(t *testing.T) {
-	apiKey, secretKey, err := lunoKeysFromEnv()
-	if err != nil {
-		t.Fatal(err)
-	}
-	lunoClient := NewClient(apiKey, secretKey)
+	lunoClient := newTestClient(t)
 
 	// now create a user
-	user := &User{
+	user := &luno.User{
 		Name:     "Bob Wood",
 		Email:    "bob@wood.com",
 		Password: "splinterz",
@@ -236,7 +234,7 @@ func TestUserLogin(t *testing.T) {
 
 	// try to login with wrong password
 	_, _, err = lunoClient.Users.LoginWithEmail("bob@wood.com", "wrong", nil, nil)
-	if !IsErrorCode(err, ErrCodeIncorrectPassword) {
+	if !luno.IsErrorCode(err, luno.ErrCodeIncorrectPassword) {
 		t.Errorf("expected error with wrong password, got %v", err)
 	}
 
@@ -254,7 +252,7 @@ func TestUserLogin(t *testing.T) {
 
 	// try to login again, expect fail because deactivated
 	_, _, err = lunoClient.Users.LoginWithEmail("bob@wood.com", "splinterz", nil, nil)
-	if !IsErrorCode(err, ErrCodeUserClosed) {
+	if !luno.IsErrorCode(err, luno.ErrCodeUserClosed) {
 		t.Errorf("expected error with wrong password, got %v", err)
 	}
 
@@ -266,14 +264,10 @@ func TestUserLogin(t *testing.T) {
 }
 
 func TestUserDeleteSessions(t *testing.T) {
-	apiKey, secretKey, err := lunoKeysFromEnv()
-	if err != nil {
-		t.Fatal(err)
-	}
-	lunoClient := NewClient(apiKey, secretKey)
+	lunoClient := newTestClient(t)
 
 	// now create a user
-	user := &User{
+	user := &luno.User{
 		Name:     "Mittens Steve",
 		Email:    "mitt@mittens.com",
 		Password: "iweargloves",
@@ -290,7 +284,7 @@ func TestUserDeleteSessions(t *testing.T) {
 	}
 
 	// get sessions for this user
-	sessions, err := lunoClient.Sessions.Recent(nil, &SessionFilter{UserID: createdUser.ID}, nil)
+	sessions, err := lunoClient.Sessions.Recent(nil, &luno.SessionFilter{UserID: createdUser.ID}, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -307,7 +301,7 @@ func TestUserDeleteSessions(t *testing.T) {
 	}
 
 	// get sessions for this user again
-	sessions, err = lunoClient.Sessions.Recent(nil, &SessionFilter{UserID: createdUser.ID}, nil)
+	sessions, err = lunoClient.Sessions.Recent(nil, &luno.SessionFilter{UserID: createdUser.ID}, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -326,14 +320,10 @@ func TestUserDeleteSessions(t *testing.T) {
 }
 
 func TestUserChangePassword(t *testing.T) {
-	apiKey, secretKey, err := lunoKeysFromEnv()
-	if err != nil {
-		t.Fatal(err)
-	}
-	lunoClient := NewClient(apiKey, secretKey)
+	lunoClient := newTestClient(t)
 
 	// now create a user
-	user := &User{
+	user := &luno.User{
 		Name:     "Charles Winchester",
 		Email:    "chuck@af.com",
 		Password: "imrich",
@@ -351,7 +341,7 @@ func TestUserChangePassword(t *testing.T) {
 
 	// try to change password, with incorrect current password
 	err = lunoClient.Users.ChangePassword(createdUser.ID, "imbroke", "notmypassword", true)
-	if !IsErrorCode(err, ErrCodeIncorrectPassword) {
+	if !luno.IsErrorCode(err, luno.ErrCodeIncorrectPassword) {
 		t.Errorf("expected incorrect password, got %v", err)
 	}
 
@@ -373,3 +363,75 @@ func TestUserChangePassword(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestUserGetByEmailAndUsername(t *testing.T) {
+	lunoClient := newTestClient(t)
+
+	user, err := lunoClient.Users.Create(&luno.User{
+		Email:    "marty+lookup@example.com",
+		UserName: "marty",
+	}, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = lunoClient.Users.Delete(user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	byEmail, err := lunoClient.Users.GetByEmail("marty+lookup@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if byEmail.ID != user.ID {
+		t.Errorf("expected user %s, got %s", user.ID, byEmail.ID)
+	}
+	byUsername, err := lunoClient.Users.GetByUsername("marty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if byUsername.ID != user.ID {
+		t.Errorf("expected user %s, got %s", user.ID, byUsername.ID)
+	}
+
+	_, err = lunoClient.Users.GetByEmail("nobody@example.com")
+	var notFound *luno.UserNotFoundError
+	if !errors.As(err, &notFound) || notFound.Field != "email" || notFound.Value != "nobody@example.com" {
+		t.Fatalf("expected user not found error, got %v", err)
+	}
+	if !errors.Is(err, luno.ErrUserNotFound) || !luno.IsNotFound(err) {
+		t.Errorf("expected error to match ErrUserNotFound, got %v", err)
+	}
+
+	results := lunoClient.Users.GetByEmailsContext(context.Background(),
+		[]string{"nobody@example.com", "marty+lookup@example.com", ""}, 2)
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	if !errors.Is(results[0].Err, luno.ErrUserNotFound) || results[0].Value != "nobody@example.com" {
+		t.Errorf("unexpected result %+v", results[0])
+	}
+	if results[1].Err != nil || results[1].User.ID != user.ID {
+		t.Errorf("unexpected result %+v", results[1])
+	}
+	if results[2].Err == nil || errors.Is(results[2].Err, luno.ErrUserNotFound) {
+		t.Errorf("expected empty email to be rejected, got %+v", results[2])
+	}
+
+	results = lunoClient.Users.GetByUsernames([]string{"marty"}, 0)
+	if results[0].Err != nil || results[0].User.ID != user.ID {
+		t.Errorf("unexpected result %+v", results[0])
+	}
+
+	// once the context is done, no more lookups are made
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results = lunoClient.Users.GetByEmailsContext(ctx, []string{"marty+lookup@example.com", "a@example.com", "b@example.com"}, 1)
+	for _, result := range results {
+		if !errors.Is(result.Err, context.Canceled) {
+			t.Errorf("expected cancelled lookup, got %+v", result)
+		}
+	}
+}